@@ -0,0 +1,565 @@
+/*
+ * Minio Cloud Storage, (C) 2019 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+// Minio server default region, returned to the bound applications.
+const defaultRegion = "us-east-1"
+
+// Policy attached to the binding user when the bind request does not specify one.
+const defaultBindingPolicy = "readwrite"
+
+// Binding user of an application is added to the group named after the
+// application GUID, so that the user can be traced back to the application.
+const appGroupPrefix = "cf-app-"
+
+// Binding user is added to the group named after the bucket created for the
+// binding, DeleteBinding finds the bucket of the binding with it.
+const bucketGroupPrefix = "cf-bucket-"
+
+// Tags set on the buckets created for the bindings, DeleteBinding checks that
+// the bucket belongs to the binding and whether it has to be purged with these.
+const (
+	bindingIDTag   = "pcf-binding-id"
+	purgeBucketTag = "pcf-purge-bucket"
+)
+
+var validBucketName = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+
+// instance - endpoint and root credentials of the instance, as configured in
+// its manifest by GenerateManifest.
+type instance struct {
+	domain    string
+	accessKey string
+	secretKey string
+	tls       bool
+}
+
+func (i instance) endpoint() string {
+	return "https://" + i.domain
+}
+
+// resolveSecret - returns the value of the variable when value is a reference
+// like ((minio_secretkey)), as resolved by ODB in secrets.
+func resolveSecret(value string, secrets serviceadapter.ManifestSecrets) string {
+	if !isReference(value) {
+		return value
+	}
+	if secret, ok := secrets[value]; ok {
+		return secret
+	}
+	return secrets[strings.TrimSuffix(strings.TrimPrefix(value, "(("), "))")]
+}
+
+func instanceFromManifest(manifest bosh.BoshManifest, secrets serviceadapter.ManifestSecrets) (i instance, err error) {
+	if manifest.Properties["credential"] == nil || manifest.Properties["domain"] == nil {
+		return i, errors.New(`"credential" or "domain" not found in the instance manifest`)
+	}
+	credential := fromPreviousManifestParameters(manifest.Properties["credential"].(map[interface{}]interface{}))
+	accessKey, _ := credential["accesskey"].(string)
+	secretKey, _ := credential["secretkey"].(string)
+	i.accessKey = resolveSecret(accessKey, secrets)
+	i.secretKey = resolveSecret(secretKey, secrets)
+	if i.accessKey == "" || i.secretKey == "" {
+		return i, errors.New(`"accesskey" or "secretkey" not found in the instance manifest, make sure ODB resolves secrets at bind`)
+	}
+	i.domain = manifest.Properties["domain"].(string)
+	i.tls = manifest.Properties["tls"] != nil
+	return i, nil
+}
+
+// bindingConfig - returns the "bindings" configuration of the plan, which
+// GenerateManifest copies in to the instance manifest.
+func bindingConfig(manifest bosh.BoshManifest) map[string]interface{} {
+	config, ok := manifest.Properties["bindings"].(map[interface{}]interface{})
+	if !ok {
+		return map[string]interface{}{}
+	}
+	return fromPreviousManifestParameters(config)
+}
+
+// defaultPermission - returns the permission of the bindings of the given kind
+// when the bind request does not specify one. Service keys are read-only unless
+// configured otherwise in the plan with "service_key_permission", applications
+// get the default policy unless configured with "app_permission".
+func defaultPermission(config map[string]interface{}, appGUID string) string {
+	if appGUID == "" {
+		if permission, ok := config["service_key_permission"].(string); ok && permission != "" {
+			return permission
+		}
+		return permissionReadOnly
+	}
+	permission, _ := config["app_permission"].(string)
+	return permission
+}
+
+// internalEndpoints - returns the endpoints of the instance reachable without
+// going through the gorouter: the BOSH DNS addresses when available,
+// otherwise the IPs of the VMs. Instances with tls are reached over https.
+func internalEndpoints(deploymentTopology bosh.BoshVMs, address serviceadapter.DNSAddresses, tls bool) []string {
+	var hosts []string
+	if len(address) > 0 {
+		for _, addr := range address {
+			hosts = append(hosts, addr)
+		}
+	} else {
+		for _, ips := range deploymentTopology {
+			hosts = append(hosts, ips...)
+		}
+	}
+	sort.Strings(hosts)
+	scheme := "http"
+	if tls {
+		scheme = "https"
+	}
+	var endpoints []string
+	for _, host := range hosts {
+		endpoints = append(endpoints, fmt.Sprintf("%s://%s:%d", scheme, host, minioPort))
+	}
+	return endpoints
+}
+
+// bindingCredhub - returns the CredHub client when the credentials of the
+// binding have to be stored in CredHub, either requested with the "credhub_ref"
+// bind parameter or with "credhub_ref" of the plan bindings configuration.
+// Cloud Controller resolves the "credhub-ref" of application bindings only,
+// service keys always get their credentials.
+func bindingCredhub(config, params map[string]interface{}, appGUID string) (*credhubClient, error) {
+	useCredhub := fmt.Sprint(config["credhub_ref"]) == "true" && appGUID != ""
+	if params["credhub_ref"] != nil {
+		var ok bool
+		if useCredhub, ok = params["credhub_ref"].(bool); !ok {
+			return nil, errors.New(`"credhub_ref" should be true or false`)
+		}
+		if useCredhub && appGUID == "" {
+			return nil, errors.New(`"credhub_ref" can be specified only for application bindings, service keys are not resolved from CredHub`)
+		}
+	}
+	if !useCredhub {
+		return nil, nil
+	}
+	credhubConfig, err := loadCredhubConfig(credhubConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	if credhubConfig == nil {
+		return nil, errors.New("CredHub is not configured for the service adapter")
+	}
+	return newCredhubClient(*credhubConfig)
+}
+
+// Endpoints which can be chosen as the primary endpoint of the binding with
+// the "endpoint" bind parameter.
+const (
+	endpointRouter   = "router"
+	endpointInternal = "internal"
+)
+
+// primaryEndpoint - returns the endpoint requested with the "endpoint" bind
+// parameter, the gorouter endpoint by default.
+func primaryEndpoint(params map[string]interface{}, routerEndpoint string, internal []string) (string, error) {
+	switch params["endpoint"] {
+	case nil, endpointRouter:
+		return routerEndpoint, nil
+	case endpointInternal:
+		if len(internal) == 0 {
+			return "", errors.New("no internal endpoint available for the instance")
+		}
+		return internal[0], nil
+	default:
+		return "", fmt.Errorf(`"endpoint" should be either "%s" or "%s"`, endpointRouter, endpointInternal)
+	}
+}
+
+// generateSecretKey - returns a random 40 character secret key.
+func generateSecretKey() (string, error) {
+	b := make([]byte, 30)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// stringList - returns the bind parameter as a list of strings.
+func stringList(params map[string]interface{}, key string) ([]string, error) {
+	if params[key] == nil {
+		return nil, nil
+	}
+	values, ok := params[key].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf(`"%s" should be a list of strings`, key)
+	}
+	var list []string
+	for _, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf(`"%s" should be a list of strings`, key)
+		}
+		list = append(list, s)
+	}
+	return list, nil
+}
+
+// bindingBucket - returns the name of the bucket to be created for the binding.
+// "bucket" bind parameter is either the name of the bucket or true, in which
+// case the bucket is named after the binding ID.
+func bindingBucket(bindingID string, params map[string]interface{}) (string, error) {
+	switch bucket := params["bucket"].(type) {
+	case nil:
+		return "", nil
+	case bool:
+		if !bucket {
+			return "", nil
+		}
+		return strings.ToLower(bindingID), nil
+	case string:
+		if bucket == "" {
+			return "", nil
+		}
+		if !validBucketName.MatchString(bucket) || strings.Contains(bucket, "..") {
+			return "", fmt.Errorf(`"%s" is not a valid bucket name`, bucket)
+		}
+		return bucket, nil
+	default:
+		return "", errors.New(`"bucket" should be the name of the bucket or true`)
+	}
+}
+
+// bindingPolicy - returns the name of the policy to be attached to the binding
+// user, along with the policy document when it has to be added to the instance
+// with the binding ID as its name. The policy is either generated from the
+// "permission", "buckets" and "prefix" bind parameters, or is given with the
+// "policy" bind parameter as the name of a canned policy (for ex. readonly,
+// writeonly, readwrite) or as a custom policy document. When a bucket is
+// created for the binding, the policy is narrowed down to it. defaultPermission
+// applies when neither "permission" nor "policy" is specified.
+func bindingPolicy(bindingID, bucket, defaultPermission string, params map[string]interface{}) (name string, document []byte, err error) {
+	if params["permission"] != nil || bucket != "" || (params["policy"] == nil && defaultPermission != "") {
+		if params["policy"] != nil {
+			return "", nil, errors.New(`"policy" can not be specified with "permission" or "bucket"`)
+		}
+		permission := permissionReadWrite
+		if defaultPermission != "" {
+			permission = defaultPermission
+		}
+		if params["permission"] != nil {
+			var ok bool
+			if permission, ok = params["permission"].(string); !ok {
+				return "", nil, errors.New(`"permission" should be a string`)
+			}
+		}
+		buckets, err := stringList(params, "buckets")
+		if err != nil {
+			return "", nil, err
+		}
+		if bucket != "" {
+			if buckets != nil {
+				return "", nil, errors.New(`"buckets" and "bucket" can not be specified together`)
+			}
+			buckets = []string{bucket}
+		}
+		prefix, ok := params["prefix"].(string)
+		if params["prefix"] != nil && !ok {
+			return "", nil, errors.New(`"prefix" should be a string`)
+		}
+		policy, err := permissionPolicy(permission, buckets, prefix)
+		if err != nil {
+			return "", nil, err
+		}
+		document, err = json.Marshal(policy)
+		return bindingID, document, err
+	}
+	if params["buckets"] != nil || params["prefix"] != nil {
+		return "", nil, errors.New(`"buckets" and "prefix" can be specified only with "permission"`)
+	}
+
+	switch policy := params["policy"].(type) {
+	case nil:
+		return defaultBindingPolicy, nil, nil
+	case string:
+		if policy == "" {
+			return defaultBindingPolicy, nil, nil
+		}
+		return policy, nil, nil
+	case map[string]interface{}:
+		document, err = json.Marshal(policy)
+		return bindingID, document, err
+	default:
+		return "", nil, errors.New(`"policy" should be the name of a canned policy or a policy document`)
+	}
+}
+
+// CreateBinding - creates a Minio user named after the binding ID, attaches the
+// requested policy to it and returns its credentials to the bound application.
+func (a adapter) CreateBinding(bindingID string, deploymentTopology bosh.BoshVMs, manifest bosh.BoshManifest, requestParams serviceadapter.RequestParameters, secrets serviceadapter.ManifestSecrets, address serviceadapter.DNSAddresses) (binding serviceadapter.Binding, err error) {
+	inst, err := instanceFromManifest(manifest, secrets)
+	if err != nil {
+		return binding, err
+	}
+	config := bindingConfig(manifest)
+	appGUID := requestParams.BindResource().AppGuid
+	if appGUID == "" && fmt.Sprint(config["require_app_guid"]) == "true" {
+		return binding, serviceadapter.NewAppGuidNotProvidedError(errors.New("service keys are not allowed for this plan"))
+	}
+
+	params := requestParams.ArbitraryParams()
+	bucket, err := bindingBucket(bindingID, params)
+	if err != nil {
+		return binding, err
+	}
+	purgeBucket, ok := params["purge_bucket"].(bool)
+	if params["purge_bucket"] != nil && !ok {
+		return binding, errors.New(`"purge_bucket" should be true or false`)
+	}
+	if purgeBucket && bucket == "" {
+		return binding, errors.New(`"purge_bucket" can be specified only with "bucket"`)
+	}
+	credentialType, err := bindingCredentialType(params)
+	if err != nil {
+		return binding, err
+	}
+	var duration time.Duration
+	if credentialType == credentialTypeSTS {
+		if duration, err = stsDuration(config, params); err != nil {
+			return binding, err
+		}
+	} else if params["duration"] != nil {
+		return binding, errors.New(`"duration" can be specified only with "sts" credential type`)
+	}
+	formats, err := credentialsFormats(params)
+	if err != nil {
+		return binding, err
+	}
+	credhub, err := bindingCredhub(config, params, appGUID)
+	if err != nil {
+		return binding, err
+	}
+	internal := internalEndpoints(deploymentTopology, address, inst.tls)
+	endpoint, err := primaryEndpoint(params, inst.endpoint(), internal)
+	if err != nil {
+		return binding, err
+	}
+	policy, document, err := bindingPolicy(bindingID, bucket, defaultPermission(config, appGUID), params)
+	if err != nil {
+		return binding, err
+	}
+	admin := newAdminClient(inst.endpoint(), inst.accessKey, inst.secretKey)
+
+	// ODB retries the bind request, a user named after the binding ID implies
+	// that the binding was already created.
+	if _, uerr := admin.UserInfo(bindingID); uerr == nil {
+		return binding, serviceadapter.NewBindingAlreadyExistsError(fmt.Errorf("user %s already exists", bindingID))
+	} else if !isAPIError(uerr, errNoSuchUser) {
+		return binding, fmt.Errorf("unable to get user %s: %s", bindingID, uerr)
+	}
+
+	if bucket != "" {
+		s3 := newS3Client(inst.endpoint(), inst.accessKey, inst.secretKey)
+		if err = s3.MakeBucket(bucket); err != nil {
+			if isAPIError(err, errBucketAlreadyOwnedByYou) || isAPIError(err, errBucketAlreadyExists) {
+				return binding, fmt.Errorf(`bucket %s already exists, use "buckets" to bind to an existing bucket`, bucket)
+			}
+			return binding, fmt.Errorf("unable to create bucket %s: %s", bucket, err)
+		}
+		// Do not leave behind a bucket which is not tracked by any binding.
+		defer func() {
+			if err != nil {
+				s3.RemoveBucket(bucket, false)
+			}
+		}()
+		tags := map[string]string{
+			bindingIDTag:   bindingID,
+			purgeBucketTag: strconv.FormatBool(purgeBucket),
+		}
+		if err = s3.SetBucketTags(bucket, tags); err != nil {
+			return binding, fmt.Errorf("unable to tag bucket %s: %s", bucket, err)
+		}
+	}
+
+	secretKey, err := generateSecretKey()
+	if err != nil {
+		return binding, err
+	}
+	if err = admin.AddUser(bindingID, secretKey); err != nil {
+		return binding, fmt.Errorf("unable to add user %s: %s", bindingID, err)
+	}
+	// Do not leave behind a user without the expected policy.
+	defer func() {
+		if err != nil {
+			admin.RemoveUser(bindingID)
+			if document != nil {
+				admin.RemoveCannedPolicy(policy)
+			}
+		}
+	}()
+
+	if document != nil {
+		if err = admin.AddCannedPolicy(policy, document); err != nil {
+			return binding, fmt.Errorf("unable to add policy %s: %s", policy, err)
+		}
+	}
+	if err = admin.SetUserPolicy(bindingID, policy); err != nil {
+		return binding, fmt.Errorf("unable to set policy %s for user %s: %s", policy, bindingID, err)
+	}
+	if appGUID != "" {
+		if err = admin.AddGroupMember(appGroupPrefix+appGUID, bindingID); err != nil {
+			return binding, fmt.Errorf("unable to add user %s to group %s: %s", bindingID, appGroupPrefix+appGUID, err)
+		}
+	}
+	if bucket != "" {
+		if err = admin.AddGroupMember(bucketGroupPrefix+bucket, bindingID); err != nil {
+			return binding, fmt.Errorf("unable to add user %s to group %s: %s", bindingID, bucketGroupPrefix+bucket, err)
+		}
+	}
+
+	creds := bindingCredentials{
+		endpoint:          endpoint,
+		routerEndpoint:    inst.endpoint(),
+		internalEndpoints: internal,
+		domain:            inst.domain,
+		accessKey:         bindingID,
+		secretKey:         secretKey,
+		region:            defaultRegion,
+		bucket:            bucket,
+	}
+	if credentialType == credentialTypeSTS {
+		// Temporary credentials carry the policy of the binding user and are
+		// revoked along with it on DeleteBinding.
+		var tmp temporaryCredentials
+		tmp, err = newSTSClient(inst.endpoint(), bindingID, secretKey).AssumeRole(duration)
+		if err != nil {
+			return binding, fmt.Errorf("unable to get temporary credentials for user %s: %s", bindingID, err)
+		}
+		creds.accessKey = tmp.AccessKey
+		creds.secretKey = tmp.SecretKey
+		creds.sessionToken = tmp.SessionToken
+		creds.expiration = tmp.Expiration
+	}
+	binding.Credentials = creds.format(formats)
+
+	if credhub != nil {
+		path := credhubPath(manifest.Name, bindingID)
+		if err = credhub.SetJSON(path, binding.Credentials); err != nil {
+			return binding, fmt.Errorf("unable to store credentials in CredHub: %s", err)
+		}
+		if appGUID != "" {
+			if err = credhub.AddReadPermission(path, appGUID); err != nil {
+				credhub.Delete(path)
+				return binding, fmt.Errorf("unable to allow application %s to read %s in CredHub: %s", appGUID, path, err)
+			}
+		}
+		binding.Credentials = map[string]interface{}{"credhub-ref": path}
+	}
+	return binding, nil
+}
+
+// removeBindingBucket - removes the bucket created for the binding. The bucket
+// is kept unless the binding was created with "purge_bucket", in which case it
+// is deleted along with all its objects.
+func removeBindingBucket(s3 *s3Client, bindingID, bucket string) error {
+	tags, err := s3.BucketTags(bucket)
+	if isAPIError(err, errNoSuchBucket) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to get tags of bucket %s: %s", bucket, err)
+	}
+	if tags[bindingIDTag] != bindingID || tags[purgeBucketTag] != "true" {
+		return nil
+	}
+	if err = s3.RemoveBucket(bucket, true); err != nil && !isAPIError(err, errNoSuchBucket) {
+		return fmt.Errorf("unable to remove bucket %s: %s", bucket, err)
+	}
+	return nil
+}
+
+// DeleteBinding - removes the binding user, its custom policy and its bucket, if any.
+func (a adapter) DeleteBinding(bindingID string, deploymentTopology bosh.BoshVMs, manifest bosh.BoshManifest, requestParams serviceadapter.RequestParameters, secrets serviceadapter.ManifestSecrets) error {
+	inst, err := instanceFromManifest(manifest, secrets)
+	if err != nil {
+		return err
+	}
+	admin := newAdminClient(inst.endpoint(), inst.accessKey, inst.secretKey)
+	info, err := admin.UserInfo(bindingID)
+	if isAPIError(err, errNoSuchUser) {
+		return serviceadapter.NewBindingNotFoundError(fmt.Errorf("user %s does not exist", bindingID))
+	}
+	if err != nil {
+		return fmt.Errorf("unable to get user %s: %s", bindingID, err)
+	}
+	// Credentials are removed from CredHub first, the binding is not found on
+	// retries once its user is removed, so everything else is removed before.
+	credhubConfig, err := loadCredhubConfig(credhubConfigFile)
+	if err != nil {
+		return err
+	}
+	if credhubConfig != nil {
+		credhub, err := newCredhubClient(*credhubConfig)
+		if err != nil {
+			return err
+		}
+		if err = credhub.Delete(credhubPath(manifest.Name, bindingID)); err != nil {
+			return fmt.Errorf("unable to delete credentials from CredHub: %s", err)
+		}
+	}
+	var bucketGroup string
+	for _, group := range info.MemberOf {
+		if strings.HasPrefix(group, bucketGroupPrefix) {
+			bucketGroup = group
+		}
+		if !strings.HasPrefix(group, appGroupPrefix) {
+			continue
+		}
+		if err = admin.RemoveGroupMember(group, bindingID); err != nil {
+			return fmt.Errorf("unable to remove user %s from group %s: %s", bindingID, group, err)
+		}
+	}
+	if err = admin.RemoveCannedPolicy(bindingID); err != nil && !isAPIError(err, errNoSuchPolicy) {
+		return fmt.Errorf("unable to remove policy %s: %s", bindingID, err)
+	}
+	// Membership of the bucket group is kept until the bucket is removed, so
+	// that retries find the bucket.
+	if bucketGroup != "" {
+		bucket := strings.TrimPrefix(bucketGroup, bucketGroupPrefix)
+		if err = removeBindingBucket(newS3Client(inst.endpoint(), inst.accessKey, inst.secretKey), bindingID, bucket); err != nil {
+			return err
+		}
+		if err = admin.RemoveGroupMember(bucketGroup, bindingID); err != nil {
+			return fmt.Errorf("unable to remove user %s from group %s: %s", bindingID, bucketGroup, err)
+		}
+	}
+	// User is removed last, ODB retries DeleteBinding until the user is gone.
+	if err = admin.RemoveUser(bindingID); err != nil && !isAPIError(err, errNoSuchUser) {
+		return fmt.Errorf("unable to remove user %s: %s", bindingID, err)
+	}
+	return nil
+}
@@ -0,0 +1,343 @@
+/*
+ * Minio Cloud Storage, (C) 2019 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+// fakeInstance - Minio admin, S3 and STS APIs of an instance, recording the
+// calls which remove the resources of the bindings.
+type fakeInstance struct {
+	*fakeAdmin
+	mu       sync.Mutex
+	policies map[string][]byte
+	buckets  map[string]map[string]string
+	removals []string
+
+	// Temporary credentials returned by AssumeRole, or denied when unset.
+	sts         *temporaryCredentials
+	stsDuration string
+}
+
+func newFakeInstance() *fakeInstance {
+	return &fakeInstance{
+		fakeAdmin: &fakeAdmin{secretKey: "adminsecret", users: map[string]userInfo{}, secrets: map[string]string{}},
+		policies:  map[string][]byte{},
+		buckets:   map[string]map[string]string{},
+	}
+}
+
+func (f *fakeInstance) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost && r.URL.Path == "/" {
+		f.serveSTS(w, r)
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, adminAPIPrefix+"/") {
+		f.serveS3(w, r)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := r.URL.Query().Get("name")
+	switch strings.TrimPrefix(r.URL.Path, adminAPIPrefix+"/") {
+	case "add-canned-policy":
+		f.policies[name], _ = ioutil.ReadAll(r.Body)
+	case "remove-canned-policy":
+		f.removals = append(f.removals, "policy "+name)
+		delete(f.policies, name)
+	case "update-group-members":
+		var g groupMembers
+		json.NewDecoder(r.Body).Decode(&g)
+		f.fakeAdmin.mu.Lock()
+		defer f.fakeAdmin.mu.Unlock()
+		for _, member := range g.Members {
+			info := f.users[member]
+			if !g.IsRemove {
+				info.MemberOf = append(info.MemberOf, g.Group)
+			} else {
+				f.removals = append(f.removals, "group "+g.Group)
+				var groups []string
+				for _, group := range info.MemberOf {
+					if group != g.Group {
+						groups = append(groups, group)
+					}
+				}
+				info.MemberOf = groups
+			}
+			f.users[member] = info
+		}
+	case "remove-user":
+		f.removals = append(f.removals, "user "+r.URL.Query().Get("accessKey"))
+		f.fakeAdmin.ServeHTTP(w, r)
+	default:
+		f.fakeAdmin.ServeHTTP(w, r)
+	}
+}
+
+func (f *fakeInstance) serveSTS(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.FormValue("Action") != "AssumeRole" || r.FormValue("Version") != "2011-06-15" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	f.stsDuration = r.FormValue("DurationSeconds")
+	// Temporary credentials are issued for the user signing the request.
+	credential := strings.TrimPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=")
+	if f.sts == nil || !strings.HasPrefix(credential, "binding-sts/") || !strings.Contains(credential, "/sts/aws4_request") {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`<ErrorResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><Error><Type></Type>` +
+			`<Code>AccessDenied</Code><Message>Access denied: user has no policy</Message></Error><RequestId>1</RequestId></ErrorResponse>`))
+		return
+	}
+	fmt.Fprintf(w, `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><AssumeRoleResult>`+
+		`<AssumedRoleUser><Arn></Arn><AssumeRoleId></AssumeRoleId></AssumedRoleUser><Credentials>`+
+		`<AccessKeyId>%s</AccessKeyId><SecretAccessKey>%s</SecretAccessKey><Expiration>%s</Expiration><SessionToken>%s</SessionToken>`+
+		`</Credentials></AssumeRoleResult><ResponseMetadata><RequestId>1</RequestId></ResponseMetadata></AssumeRoleResponse>`,
+		f.sts.AccessKey, f.sts.SecretKey, f.sts.Expiration.Format(time.RFC3339), f.sts.SessionToken)
+}
+
+func (f *fakeInstance) serveS3(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fail := func(status int, code string) {
+		w.WriteHeader(status)
+		xml.NewEncoder(w).Encode(apiError{Code: code})
+	}
+	bucket := strings.TrimPrefix(r.URL.Path, "/")
+	tags, ok := f.buckets[bucket]
+	if !ok && r.Method != http.MethodPut {
+		fail(http.StatusNotFound, errNoSuchBucket)
+		return
+	}
+	_, tagged := r.URL.Query()["tagging"]
+	switch {
+	case r.Method == http.MethodPut && !tagged:
+		f.buckets[bucket] = map[string]string{}
+	case r.Method == http.MethodPut:
+		var t tagging
+		xml.NewDecoder(r.Body).Decode(&t)
+		for _, tag := range t.TagSet {
+			f.buckets[bucket][tag.Key] = tag.Value
+		}
+	case r.Method == http.MethodGet && tagged:
+		if len(tags) == 0 {
+			fail(http.StatusNotFound, errNoSuchTagSet)
+			return
+		}
+		var t tagging
+		for k, v := range tags {
+			t.TagSet = append(t.TagSet, tag{k, v})
+		}
+		xml.NewEncoder(w).Encode(t)
+	case r.Method == http.MethodDelete:
+		f.removals = append(f.removals, "bucket "+bucket)
+		delete(f.buckets, bucket)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		fail(http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+// testInstanceManifest - returns the manifest of an instance served by server,
+// along with its secrets.
+func testInstanceManifest(t *testing.T, server *httptest.Server) (bosh.BoshManifest, serviceadapter.ManifestSecrets) {
+	output, err := generateTestManifest(t, testServicePlan("4"), nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := reloadManifest(t, output.Manifest)
+	manifest.Properties["domain"] = strings.TrimPrefix(server.URL, "https://")
+	return *manifest, serviceadapter.ManifestSecrets{"minio_accesskey": "admin", "minio_secretkey": "adminsecret"}
+}
+
+func TestDeleteBinding(t *testing.T) {
+	instance := newFakeInstance()
+	server := httptest.NewTLSServer(instance)
+	defer server.Close()
+	defer func(transport http.RoundTripper) { http.DefaultTransport = transport }(http.DefaultTransport)
+	http.DefaultTransport = server.Client().Transport
+	manifest, secrets := testInstanceManifest(t, server)
+
+	testCases := []struct {
+		params   map[string]interface{}
+		removals []string
+		buckets  []string
+	}{
+		{map[string]interface{}{"bucket": "purged", "purge_bucket": true},
+			[]string{"policy binding-1", "bucket purged", "group cf-bucket-purged", "user binding-1"}, []string{"kept"}},
+		{map[string]interface{}{"bucket": "kept"},
+			[]string{"policy binding-2", "group cf-bucket-kept", "user binding-2"}, []string{"kept"}},
+		{map[string]interface{}{"policy": "readonly"},
+			[]string{"policy binding-3", "user binding-3"}, []string{"kept"}},
+	}
+	for i, tc := range testCases {
+		bindingID := fmt.Sprintf("binding-%d", i+1)
+		requestParams := serviceadapter.RequestParameters{"parameters": tc.params}
+		if _, err := (adapter{}).CreateBinding(bindingID, nil, manifest, requestParams, secrets, nil); err != nil {
+			t.Fatalf("%s: %s", bindingID, err)
+		}
+	}
+	for i, tc := range testCases {
+		bindingID := fmt.Sprintf("binding-%d", i+1)
+		instance.removals = nil
+		if err := (adapter{}).DeleteBinding(bindingID, nil, manifest, nil, secrets); err != nil {
+			t.Fatalf("%s: %s", bindingID, err)
+		}
+		if !reflect.DeepEqual(instance.removals, tc.removals) {
+			t.Errorf("%s: expected removals %q, got %q", bindingID, tc.removals, instance.removals)
+		}
+		// Buckets of the other bindings are left untouched.
+		var buckets []string
+		for bucket := range instance.buckets {
+			buckets = append(buckets, bucket)
+		}
+		if !reflect.DeepEqual(buckets, tc.buckets) {
+			t.Errorf("%s: expected buckets %q, got %q", bindingID, tc.buckets, buckets)
+		}
+		err := (adapter{}).DeleteBinding(bindingID, nil, manifest, nil, secrets)
+		if _, ok := err.(serviceadapter.BindingNotFoundError); !ok {
+			t.Errorf("%s: expected the binding not to be found, got %v", bindingID, err)
+		}
+	}
+}
+
+func TestCredhubRefOfBindings(t *testing.T) {
+	instance := newFakeInstance()
+	server := httptest.NewTLSServer(instance)
+	defer server.Close()
+	defer func(transport http.RoundTripper) { http.DefaultTransport = transport }(http.DefaultTransport)
+	http.DefaultTransport = server.Client().Transport
+	manifest, secrets := testInstanceManifest(t, server)
+	manifest.Properties["bindings"] = map[interface{}]interface{}{"credhub_ref": true}
+
+	credhub := &fakeCredhub{t: t, credentials: map[string]interface{}{}}
+	credhubServer := httptest.NewTLSServer(credhub)
+	defer credhubServer.Close()
+	dir, err := ioutil.TempDir("", "credhub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	config := newTestCredhubClient(t, credhubServer, "adapter-secret").config
+	b, _ := json.Marshal(config)
+	defer func(file string) { credhubConfigFile = file }(credhubConfigFile)
+	credhubConfigFile = filepath.Join(dir, "credhub.json")
+	if err = ioutil.WriteFile(credhubConfigFile, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Application bindings get a reference readable by the application.
+	appBinding := serviceadapter.RequestParameters{"bind_resource": map[string]interface{}{"app_guid": "app-guid"}}
+	binding, err := adapter{}.CreateBinding("binding-app", nil, manifest, appBinding, secrets, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := credhubPath(manifest.Name, "binding-app")
+	if !reflect.DeepEqual(binding.Credentials, map[string]interface{}{"credhub-ref": path}) {
+		t.Fatalf("unexpected credentials %v", binding.Credentials)
+	}
+	if credhub.credentials[path] == nil || len(credhub.permissions) != 1 || credhub.permissions[0]["actor"] != "mtls-app:app-guid" {
+		t.Fatalf("unexpected CredHub credentials %v and permissions %v", credhub.credentials, credhub.permissions)
+	}
+
+	// Service keys get their credentials.
+	binding, err = adapter{}.CreateBinding("binding-key", nil, manifest, serviceadapter.RequestParameters{}, secrets, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if binding.Credentials["credhub-ref"] != nil || binding.Credentials["accesskey"] != "binding-key" {
+		t.Fatalf("unexpected credentials %v", binding.Credentials)
+	}
+	if len(credhub.credentials) != 1 {
+		t.Fatalf("unexpected CredHub credentials %v", credhub.credentials)
+	}
+	_, err = adapter{}.CreateBinding("binding-ref", nil, manifest, serviceadapter.RequestParameters{"parameters": map[string]interface{}{"credhub_ref": true}}, secrets, nil)
+	if err == nil || !strings.Contains(err.Error(), `"credhub_ref" can be specified only for application bindings`) {
+		t.Fatalf("expected credhub_ref to be refused for service keys, got %v", err)
+	}
+
+	if err = (adapter{}).DeleteBinding("binding-app", nil, manifest, nil, secrets); err != nil {
+		t.Fatal(err)
+	}
+	if len(credhub.credentials) != 0 {
+		t.Fatalf("expected the CredHub credentials to be deleted, got %v", credhub.credentials)
+	}
+}
+
+func TestSTSBinding(t *testing.T) {
+	instance := newFakeInstance()
+	server := httptest.NewTLSServer(instance)
+	defer server.Close()
+	defer func(transport http.RoundTripper) { http.DefaultTransport = transport }(http.DefaultTransport)
+	http.DefaultTransport = server.Client().Transport
+	manifest, secrets := testInstanceManifest(t, server)
+	host := strings.TrimPrefix(server.URL, "https://")
+
+	params := map[string]interface{}{"credential_type": "sts", "duration": "2h", "format": []interface{}{"standard", "aws", "mc"}}
+	_, err := adapter{}.CreateBinding("binding-sts", nil, manifest, serviceadapter.RequestParameters{"parameters": params}, secrets, nil)
+	if err == nil || err.Error() != "unable to get temporary credentials for user binding-sts: Access denied: user has no policy" {
+		t.Fatalf("expected the error of AssumeRole, got %v", err)
+	}
+	if _, ok := instance.users["binding-sts"]; ok {
+		t.Fatal("expected the user to be removed")
+	}
+
+	expiration := time.Date(2020, 6, 1, 14, 0, 0, 0, time.UTC)
+	instance.sts = &temporaryCredentials{AccessKey: "TMPACCESSKEY", SecretKey: "tmp/secret+key", SessionToken: "session-token", Expiration: expiration}
+	binding, err := adapter{}.CreateBinding("binding-sts", nil, manifest, serviceadapter.RequestParameters{"parameters": params}, secrets, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if instance.stsDuration != "7200" {
+		t.Fatalf("unexpected duration %s", instance.stsDuration)
+	}
+	expected := map[string]interface{}{
+		"endpoint":              server.URL,
+		"router_endpoint":       server.URL,
+		"accesskey":             "TMPACCESSKEY",
+		"secretkey":             "tmp/secret+key",
+		"session_token":         "session-token",
+		"expiration":            "2020-06-01T14:00:00Z",
+		"region":                "us-east-1",
+		"AWS_ACCESS_KEY_ID":     "TMPACCESSKEY",
+		"AWS_SECRET_ACCESS_KEY": "tmp/secret+key",
+		"AWS_SESSION_TOKEN":     "session-token",
+		"AWS_ENDPOINT_URL":      server.URL,
+		"AWS_REGION":            "us-east-1",
+		"mc_alias":              "export MC_HOST_minio=https://TMPACCESSKEY:tmp%2Fsecret%2Bkey:session-token@" + host,
+	}
+	if !reflect.DeepEqual(binding.Credentials, expected) {
+		t.Fatalf("unexpected credentials %v", binding.Credentials)
+	}
+}
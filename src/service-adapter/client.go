@@ -0,0 +1,94 @@
+/*
+ * Minio Cloud Storage, (C) 2019 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// apiError - error response of the Minio admin (JSON) and S3 (XML) APIs.
+type apiError struct {
+	Code       string `json:"Code" xml:"Code"`
+	Message    string `json:"Message" xml:"Message"`
+	StatusCode int    `json:"-" xml:"-"`
+}
+
+func (e apiError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("minio returned %d %s", e.StatusCode, e.Code)
+	}
+	return e.Message
+}
+
+func isAPIError(err error, code string) bool {
+	aerr, ok := err.(apiError)
+	return ok && aerr.Code == code
+}
+
+// minioClient - sends signed requests to an instance. endpoint is the base URL
+// of the instance, for ex. https://351c705a-6210-4b5e-b853-472fc8cd7646.sys.pie-27.cfplatformeng.com
+type minioClient struct {
+	endpoint  string
+	accessKey string
+	secretKey string
+	region    string
+	service   string
+	client    *http.Client
+}
+
+func newMinioClient(endpoint, accessKey, secretKey string) minioClient {
+	return minioClient{
+		endpoint:  strings.TrimSuffix(endpoint, "/"),
+		accessKey: accessKey,
+		secretKey: secretKey,
+		region:    defaultRegion,
+		service:   "s3",
+		client:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// execute - sends the signed request, returns the response body and status code.
+func (c minioClient) execute(method, path string, values url.Values, header http.Header, body []byte) ([]byte, int, error) {
+	u := c.endpoint + path
+	if len(values) > 0 {
+		u += "?" + values.Encode()
+	}
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, 0, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	signV4(req, body, c.accessKey, c.secretKey, c.region, c.service)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	return b, resp.StatusCode, nil
+}
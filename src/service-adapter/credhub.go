@@ -0,0 +1,192 @@
+/*
+ * Minio Cloud Storage, (C) 2019 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// CredHub configuration rendered by the odb-service-adapter job.
+var credhubConfigFile = "/var/vcap/jobs/odb-service-adapter/config/credhub.json"
+
+// Binding credentials are stored under this path in CredHub, followed by the
+// deployment name and the binding ID.
+const credhubPathPrefix = "/c/minio-pcf-adapter"
+
+type credhubConfig struct {
+	APIURL       string `json:"api_url"`
+	UAAURL       string `json:"uaa_url"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	CACert       string `json:"ca_cert"`
+}
+
+// loadCredhubConfig - returns the CredHub configuration, nil if CredHub is not
+// configured for the adapter.
+func loadCredhubConfig(file string) (*credhubConfig, error) {
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	config := &credhubConfig{}
+	if err = json.Unmarshal(b, config); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %s", file, err)
+	}
+	if config.APIURL == "" {
+		return nil, nil
+	}
+	return config, nil
+}
+
+func credhubPath(deploymentName, bindingID string) string {
+	return fmt.Sprintf("%s/%s/%s/credentials", credhubPathPrefix, deploymentName, bindingID)
+}
+
+// credhubClient - minimal client for the CredHub API, authenticated with UAA
+// client credentials.
+type credhubClient struct {
+	config credhubConfig
+	client *http.Client
+	token  string
+}
+
+func newCredhubClient(config credhubConfig) (*credhubClient, error) {
+	transport := &http.Transport{}
+	if config.CACert != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(config.CACert)) {
+			return nil, errors.New("unable to parse CredHub CA certificate")
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	return &credhubClient{
+		config: config,
+		client: &http.Client{Timeout: 30 * time.Second, Transport: transport},
+	}, nil
+}
+
+// login - fetches an access token from UAA.
+func (c *credhubClient) login() error {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(c.config.UAAURL, "/")+"/oauth/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(url.QueryEscape(c.config.ClientID), url.QueryEscape(c.config.ClientSecret))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("UAA returned %s", resp.Status)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return err
+	}
+	c.token = token.AccessToken
+	return nil
+}
+
+func (c *credhubClient) do(method, api string, values url.Values, body interface{}) (int, error) {
+	if c.token == "" {
+		if err := c.login(); err != nil {
+			return 0, fmt.Errorf("unable to authenticate with UAA: %s", err)
+		}
+	}
+	var b []byte
+	if body != nil {
+		var err error
+		if b, err = json.Marshal(body); err != nil {
+			return 0, err
+		}
+	}
+	u := strings.TrimSuffix(c.config.APIURL, "/") + api
+	if len(values) > 0 {
+		u += "?" + values.Encode()
+	}
+	req, err := http.NewRequest(method, u, bytes.NewReader(b))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var cerr struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&cerr)
+		if cerr.Error == "" {
+			cerr.Error = resp.Status
+		}
+		return resp.StatusCode, fmt.Errorf("CredHub returned %s", cerr.Error)
+	}
+	return resp.StatusCode, nil
+}
+
+// SetJSON - writes the value as a JSON credential at the path.
+func (c *credhubClient) SetJSON(path string, value map[string]interface{}) error {
+	_, err := c.do(http.MethodPut, "/api/v1/data", nil, map[string]interface{}{
+		"name":  path,
+		"type":  "json",
+		"value": value,
+	})
+	return err
+}
+
+// AddReadPermission - allows the application to read the credential at the path.
+func (c *credhubClient) AddReadPermission(path, appGUID string) error {
+	_, err := c.do(http.MethodPost, "/api/v2/permissions", nil, map[string]interface{}{
+		"path":       path,
+		"actor":      "mtls-app:" + appGUID,
+		"operations": []string{"read"},
+	})
+	return err
+}
+
+// Delete - deletes the credential at the path, a missing credential is ignored.
+func (c *credhubClient) Delete(path string) error {
+	status, err := c.do(http.MethodDelete, "/api/v1/data", url.Values{"name": {path}}, nil)
+	if status == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
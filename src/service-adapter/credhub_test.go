@@ -0,0 +1,156 @@
+/*
+ * Minio Cloud Storage, (C) 2019 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeCredhub - UAA and CredHub APIs, recording the credentials and the
+// permissions set.
+type fakeCredhub struct {
+	t           *testing.T
+	logins      int
+	credentials map[string]interface{}
+	permissions []map[string]interface{}
+}
+
+func (f *fakeCredhub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/oauth/token" {
+		clientID, clientSecret, _ := r.BasicAuth()
+		if r.Method != http.MethodPost || clientID != "adapter" || clientSecret != "adapter-secret" ||
+			r.FormValue("grant_type") != "client_credentials" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		f.logins++
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "token", "token_type": "bearer"})
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_token"})
+		return
+	}
+	var body map[string]interface{}
+	if r.Method != http.MethodDelete {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			f.t.Errorf("%s %s: %s", r.Method, r.URL.Path, err)
+		}
+	}
+	switch {
+	case r.Method == http.MethodPut && r.URL.Path == "/api/v1/data":
+		if body["type"] != "json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.credentials[body["name"].(string)] = body["value"]
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v2/permissions":
+		f.permissions = append(f.permissions, body)
+	case r.Method == http.MethodDelete && r.URL.Path == "/api/v1/data":
+		name := r.URL.Query().Get("name")
+		if f.credentials[name] == nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "The request could not be completed because the credential does not exist or you do not have sufficient authorization."})
+			return
+		}
+		delete(f.credentials, name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		f.t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newTestCredhubClient(t *testing.T, server *httptest.Server, clientSecret string) *credhubClient {
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	client, err := newCredhubClient(credhubConfig{
+		APIURL:       server.URL,
+		UAAURL:       server.URL + "/",
+		ClientID:     "adapter",
+		ClientSecret: clientSecret,
+		CACert:       string(ca),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestCredhubClient(t *testing.T) {
+	fake := &fakeCredhub{t: t, credentials: map[string]interface{}{}}
+	server := httptest.NewTLSServer(fake)
+	defer server.Close()
+	client := newTestCredhubClient(t, server, "adapter-secret")
+
+	path := credhubPath("service-instance_3a8c5ba6", "binding-1")
+	if path != "/c/minio-pcf-adapter/service-instance_3a8c5ba6/binding-1/credentials" {
+		t.Fatalf("unexpected path %s", path)
+	}
+	credentials := map[string]interface{}{"accessKey": "binding-1", "secretKey": "secret"}
+	if err := client.SetJSON(path, credentials); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fake.credentials[path], credentials) {
+		t.Fatalf("unexpected credentials %v", fake.credentials)
+	}
+	if err := client.AddReadPermission(path, "app-guid"); err != nil {
+		t.Fatal(err)
+	}
+	expected := []map[string]interface{}{{"path": path, "actor": "mtls-app:app-guid", "operations": []interface{}{"read"}}}
+	if !reflect.DeepEqual(fake.permissions, expected) {
+		t.Fatalf("unexpected permissions %v", fake.permissions)
+	}
+	if err := client.Delete(path); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.credentials) != 0 {
+		t.Fatalf("expected the credentials to be deleted, got %v", fake.credentials)
+	}
+	// Retries of DeleteBinding find the credentials deleted.
+	if err := client.Delete(path); err != nil {
+		t.Fatalf("expected a missing credential to be ignored, got %s", err)
+	}
+	if fake.logins != 1 {
+		t.Fatalf("expected a single login, got %d", fake.logins)
+	}
+}
+
+func TestCredhubClientErrors(t *testing.T) {
+	fake := &fakeCredhub{t: t, credentials: map[string]interface{}{}}
+	server := httptest.NewTLSServer(fake)
+	defer server.Close()
+
+	err := newTestCredhubClient(t, server, "wrong").SetJSON("/c/path", map[string]interface{}{})
+	if err == nil || !strings.Contains(err.Error(), "unable to authenticate with UAA: UAA returned 401") {
+		t.Fatalf("expected the login to fail, got %v", err)
+	}
+	client := newTestCredhubClient(t, server, "adapter-secret")
+	client.token = "expired"
+	if err = client.Delete("/c/path"); err == nil || err.Error() != "CredHub returned invalid_token" {
+		t.Fatalf("expected the error of CredHub, got %v", err)
+	}
+	if _, err = newCredhubClient(credhubConfig{APIURL: server.URL, CACert: "not a certificate"}); err == nil {
+		t.Fatal("expected the CA certificate to be refused")
+	}
+}
@@ -0,0 +1,170 @@
+/*
+ * Minio Cloud Storage, (C) 2019 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+// gatewayBackend - minio job running the gateway to a backend, and the
+// parameters of the backend.
+type gatewayBackend struct {
+	job      string
+	name     string
+	required []string
+	optional []string
+
+	// Root credentials of the instance are the credentials of the backend,
+	// they can not be rotated by the adapter.
+	backendKeys bool
+
+	// Validates the parameters of the backend, secret parameters included.
+	validate func(params map[string]interface{}) error
+}
+
+// gatewayBackends - backends which can be chosen with the "gateway" parameter.
+// The s3, b2 and oss gateways authenticate to their backend with the accesskey
+// and secretkey of the instance, azure with its storage account.
+var gatewayBackends = map[string]gatewayBackend{
+	"azure": {job: "minio-azure", name: "Azure", required: []string{"azure_storage_account", "azure_storage_key"}, optional: []string{"gateway_endpoint"}, validate: validateAzureCredentials, backendKeys: true},
+	"gcs":   {job: "minio-gcs", name: "GCS", required: []string{"googlecredentials"}, optional: []string{"project_id"}, validate: validateGoogleCredentials},
+	"s3":    {job: "minio-s3", name: "S3", required: []string{"accesskey", "secretkey"}, optional: []string{"gateway_endpoint"}, backendKeys: true},
+	"nas":   {job: "minio-nas", name: "NAS", required: []string{"nas_path"}},
+	"hdfs":  {job: "minio-hdfs", name: "HDFS", required: []string{"hdfs_namenode"}},
+	"b2":    {job: "minio-b2", name: "B2", required: []string{"accesskey", "secretkey"}, backendKeys: true},
+	"oss":   {job: "minio-oss", name: "OSS", required: []string{"accesskey", "secretkey"}, optional: []string{"gateway_endpoint"}, backendKeys: true},
+}
+
+// Parameters which are specific to some gateway backends.
+var gatewayParameters = []string{"googlecredentials", "project_id", "azure_storage_account", "azure_storage_key", "gateway_endpoint", "nas_path", "hdfs_namenode"}
+
+// Storage account names are made of 3 to 24 lowercase letters and digits.
+const azureStorageAccountPattern = `^[a-z0-9]{3,24}$`
+
+var validAzureStorageAccount = regexp.MustCompile(azureStorageAccountPattern)
+
+// gatewayNames - returns the sorted names of the gateway backends.
+func gatewayNames() []string {
+	var names []string
+	for name := range gatewayBackends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (b gatewayBackend) accepts(parameter string) bool {
+	for _, p := range append(b.required, b.optional...) {
+		if p == parameter {
+			return true
+		}
+	}
+	return false
+}
+
+// validateGatewayParameters - checks that params provide the parameters
+// required by the backend of the gateway, and no parameter of other backends.
+// Secret parameters are references in params, their values are in odbSecrets.
+func validateGatewayParameters(gateway string, params map[string]interface{}, odbSecrets serviceadapter.ODBManagedSecrets) error {
+	backend := gatewayBackends[gateway]
+	for _, p := range gatewayParameters {
+		if params[p] != nil && !backend.accepts(p) {
+			var backends []string
+			for _, name := range gatewayNames() {
+				if gatewayBackends[name].accepts(p) {
+					backends = append(backends, name)
+				}
+			}
+			return fmt.Errorf(`"%s" can be specified only for %s gateways`, p, strings.Join(backends, " and "))
+		}
+	}
+	var missing []string
+	for _, p := range backend.required {
+		if params[p] == nil {
+			missing = append(missing, p)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s should be provided for %s", strings.Join(missing, " and "), backend.name)
+	}
+	if backend.validate == nil {
+		return nil
+	}
+	values := make(map[string]interface{})
+	for k, v := range params {
+		values[k] = v
+	}
+	for k, v := range odbSecrets {
+		values[k] = v
+	}
+	return backend.validate(values)
+}
+
+// normalizeAzureCredentials - azure gateways created before the
+// "azure_storage_account" and "azure_storage_key" parameters have the name and
+// key of their storage account as "accesskey" and "secretkey", these are moved
+// on update. The storage account is the root credential of azure gateways.
+func normalizeAzureCredentials(params map[string]interface{}, update bool) error {
+	if params["gateway"] != "azure" {
+		return nil
+	}
+	if update && params["azure_storage_account"] == nil && params["azure_storage_key"] == nil && params["accesskey"] != nil {
+		params["azure_storage_account"] = params["accesskey"]
+		params["azure_storage_key"] = params["secretkey"]
+		delete(params, "accesskey")
+		delete(params, "secretkey")
+		return nil
+	}
+	if params["accesskey"] != nil || params["secretkey"] != nil {
+		return errors.New(`"accesskey" and "secretkey" can not be specified for azure gateway, its root credential is the storage account`)
+	}
+	return nil
+}
+
+// validateAzureCredentials - validates the storage account name and key of the
+// azure gateway.
+func validateAzureCredentials(params map[string]interface{}) error {
+	account, _ := params["azure_storage_account"].(string)
+	if !validAzureStorageAccount.MatchString(account) {
+		return errors.New(`"azure_storage_account" should be made of 3 to 24 lowercase letters and digits`)
+	}
+	key, _ := params["azure_storage_key"].(string)
+	if b, err := base64.StdEncoding.DecodeString(key); err != nil || len(b) == 0 {
+		return errors.New(`"azure_storage_key" should be base64 encoded`)
+	}
+	return nil
+}
+
+// gatewaySettings - returns the "gateway" property of the minio job, the
+// secret parameters of the backend are in the "credential" property.
+func gatewaySettings(gateway string, params map[string]interface{}) map[string]interface{} {
+	settings := map[string]interface{}{"backend": gateway}
+	backend := gatewayBackends[gateway]
+	for _, p := range append(backend.required, backend.optional...) {
+		if params[p] != nil && !isSecretParameter(p) {
+			settings[p] = params[p]
+		}
+	}
+	return settings
+}
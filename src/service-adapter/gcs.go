@@ -0,0 +1,79 @@
+/*
+ * Minio Cloud Storage, (C) 2019 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// googleCredentials - the fields of a Google Cloud service account key used
+// by the gcs gateway.
+type googleCredentials struct {
+	Type        string `json:"type"`
+	ProjectID   string `json:"project_id"`
+	PrivateKey  string `json:"private_key"`
+	ClientEmail string `json:"client_email"`
+}
+
+// normalizeGoogleCredentials - replaces "googlecredentials" given as a JSON
+// object by its JSON string, as kept in the ODB-managed secrets.
+func normalizeGoogleCredentials(params map[string]interface{}) error {
+	credentials, ok := params["googlecredentials"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	b, err := json.Marshal(credentials)
+	if err != nil {
+		return fmt.Errorf(`"googlecredentials" is not valid: %s`, err)
+	}
+	params["googlecredentials"] = string(b)
+	return nil
+}
+
+// validateGoogleCredentials - validates the service account key of the gcs
+// gateway, its project can be overridden with the "project_id" parameter.
+func validateGoogleCredentials(params map[string]interface{}) error {
+	value, _ := params["googlecredentials"].(string)
+	var credentials googleCredentials
+	if err := json.Unmarshal([]byte(value), &credentials); err != nil {
+		return fmt.Errorf(`"googlecredentials" is not valid JSON: %s`, err)
+	}
+	if credentials.Type != "service_account" {
+		return fmt.Errorf(`"googlecredentials" should be a service account key, its "type" is %q instead of "service_account"`, credentials.Type)
+	}
+	if credentials.ProjectID == "" && params["project_id"] == nil {
+		return errors.New(`"googlecredentials" has no "project_id", it should be provided with the "project_id" parameter`)
+	}
+	if !strings.Contains(credentials.ClientEmail, "@") {
+		return fmt.Errorf(`"client_email" of "googlecredentials" is not valid: %q`, credentials.ClientEmail)
+	}
+	block, _ := pem.Decode([]byte(credentials.PrivateKey))
+	if block == nil {
+		return errors.New(`"private_key" of "googlecredentials" is not a PEM encoded key`)
+	}
+	if _, err := x509.ParsePKCS8PrivateKey(block.Bytes); err != nil {
+		if _, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return fmt.Errorf(`"private_key" of "googlecredentials" is not valid: %s`, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+/*
+ * Minio Cloud Storage, (C) 2019 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testGoogleCredentials - returns a service account key, as an object.
+func testGoogleCredentials(t *testing.T) map[string]interface{} {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]interface{}{
+		"type":         "service_account",
+		"project_id":   "my-project",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: b})),
+		"client_email": "minio@my-project.iam.gserviceaccount.com",
+	}
+}
+
+func googleCredentialsString(t *testing.T, credentials map[string]interface{}) string {
+	b, err := json.Marshal(credentials)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestGCSGateway(t *testing.T) {
+	credentials := testGoogleCredentials(t)
+	output, err := generateTestManifest(t, testServicePlan("1"), map[string]interface{}{
+		"gateway": "gcs", "googlecredentials": credentials,
+	}, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGoldenManifest(t, "gateway-gcs", output.Manifest)
+
+	// Credentials given as an object are kept as a single JSON string.
+	secret, ok := output.ODBManagedSecrets["googlecredentials"].(string)
+	if !ok {
+		t.Fatalf("expected googlecredentials to be a string, got %v", output.ODBManagedSecrets["googlecredentials"])
+	}
+	var stored map[string]interface{}
+	if err = json.Unmarshal([]byte(secret), &stored); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(stored, credentials) {
+		t.Fatalf("unexpected googlecredentials %v", stored)
+	}
+}
+
+func TestValidateGoogleCredentials(t *testing.T) {
+	testCases := []struct {
+		name    string
+		change  func(credentials map[string]interface{})
+		params  map[string]interface{}
+		refused string
+	}{
+		{"valid", func(c map[string]interface{}) {}, nil, ""},
+		{"wrong type", func(c map[string]interface{}) { c["type"] = "authorized_user" }, nil, `its "type" is "authorized_user" instead of "service_account"`},
+		{"no project", func(c map[string]interface{}) { delete(c, "project_id") }, nil, `has no "project_id"`},
+		{"project override", func(c map[string]interface{}) { delete(c, "project_id") }, map[string]interface{}{"project_id": "other-project"}, ""},
+		{"bad client email", func(c map[string]interface{}) { c["client_email"] = "minio" }, nil, `"client_email" of "googlecredentials" is not valid: "minio"`},
+		{"no client email", func(c map[string]interface{}) { delete(c, "client_email") }, nil, `"client_email" of "googlecredentials" is not valid`},
+		{"non PEM key", func(c map[string]interface{}) { c["private_key"] = "not a key" }, nil, `is not a PEM encoded key`},
+		{"bad key", func(c map[string]interface{}) {
+			c["private_key"] = string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("not a key")}))
+		}, nil, `"private_key" of "googlecredentials" is not valid`},
+	}
+	for _, tc := range testCases {
+		credentials := testGoogleCredentials(t)
+		tc.change(credentials)
+		params := map[string]interface{}{"googlecredentials": googleCredentialsString(t, credentials)}
+		for k, v := range tc.params {
+			params[k] = v
+		}
+		err := validateGoogleCredentials(params)
+		if tc.refused == "" && err != nil {
+			t.Errorf("%s: unexpected error %s", tc.name, err)
+		}
+		if tc.refused != "" && (err == nil || !strings.Contains(err.Error(), tc.refused)) {
+			t.Errorf("%s: expected %q, got %v", tc.name, tc.refused, err)
+		}
+	}
+	if err := validateGoogleCredentials(map[string]interface{}{"googlecredentials": "{"}); err == nil || !strings.Contains(err.Error(), "is not valid JSON") {
+		t.Errorf("expected the JSON to be refused, got %v", err)
+	}
+
+	// Refused by GenerateManifest as well.
+	credentials := testGoogleCredentials(t)
+	credentials["type"] = "authorized_user"
+	_, err := generateTestManifest(t, testServicePlan("1"), map[string]interface{}{
+		"gateway": "gcs", "googlecredentials": credentials,
+	}, nil, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), `instead of "service_account"`) {
+		t.Fatalf("expected the credentials to be refused, got %v", err)
+	}
+}
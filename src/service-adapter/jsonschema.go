@@ -0,0 +1,186 @@
+/*
+ * Minio Cloud Storage, (C) 2019 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// validateSchema - validates the value against the JSON schema and returns
+// every violation. Only the parts of JSON schema used by the schemas of the
+// adapter are supported: type, enum, properties, required,
+// additionalProperties, items, minItems, minLength, maxLength, pattern and oneOf.
+func validateSchema(schema map[string]interface{}, value interface{}) []string {
+	return validateValue("", schema, value)
+}
+
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case int:
+		return "integer"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func schemaTypes(schema map[string]interface{}) []string {
+	switch t := schema["type"].(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		var types []string
+		for _, v := range t {
+			types = append(types, v.(string))
+		}
+		return types
+	}
+	return nil
+}
+
+func schemaInt(schema map[string]interface{}, key string) (int, bool) {
+	switch v := schema[key].(type) {
+	case int:
+		return v, true
+	case float64:
+		return int(v), true
+	}
+	return 0, false
+}
+
+func describe(path string) string {
+	if path == "" {
+		return "parameters"
+	}
+	return fmt.Sprintf("%q", path)
+}
+
+func validateValue(path string, schema map[string]interface{}, value interface{}) (errs []string) {
+	if types := schemaTypes(schema); types != nil {
+		vt := jsonType(value)
+		valid := false
+		for _, t := range types {
+			if t == vt || (t == "number" && vt == "integer") {
+				valid = true
+			}
+		}
+		if !valid {
+			return []string{fmt.Sprintf("%s should be of type %s", describe(path), strings.Join(types, " or "))}
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		valid := false
+		var values []string
+		for _, e := range enum {
+			if e == value {
+				valid = true
+			}
+			b, _ := json.Marshal(e)
+			values = append(values, string(b))
+		}
+		if !valid {
+			errs = append(errs, fmt.Sprintf("%s should be one of %s", describe(path), strings.Join(values, ", ")))
+		}
+	}
+
+	if oneOf, ok := schema["oneOf"].([]interface{}); ok {
+		matched := 0
+		for _, s := range oneOf {
+			if len(validateValue(path, s.(map[string]interface{}), value)) == 0 {
+				matched++
+			}
+		}
+		if matched != 1 {
+			errs = append(errs, fmt.Sprintf("%s is not valid: %s", describe(path), schema["description"]))
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		if min, ok := schemaInt(schema, "minLength"); ok && utf8.RuneCountInString(v) < min {
+			errs = append(errs, fmt.Sprintf("%s should be at least %d characters long", describe(path), min))
+		}
+		if max, ok := schemaInt(schema, "maxLength"); ok && utf8.RuneCountInString(v) > max {
+			errs = append(errs, fmt.Sprintf("%s should be at most %d characters long", describe(path), max))
+		}
+		if pattern, ok := schema["pattern"].(string); ok && !regexp.MustCompile(pattern).MatchString(v) {
+			errs = append(errs, fmt.Sprintf("%s is not valid: %s", describe(path), schema["description"]))
+		}
+	case []interface{}:
+		if min, ok := schemaInt(schema, "minItems"); ok && len(v) < min {
+			errs = append(errs, fmt.Sprintf("%s should have at least %d items", describe(path), min))
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				errs = append(errs, validateValue(fmt.Sprintf("%s[%d]", path, i), items, item)...)
+			}
+		}
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, r := range required {
+				if _, ok := v[r.(string)]; !ok {
+					errs = append(errs, fmt.Sprintf("%s is required", describe(joinPath(path, r.(string)))))
+				}
+			}
+		}
+		var keys []string
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			p, ok := properties[k].(map[string]interface{})
+			if !ok {
+				if schema["additionalProperties"] == false {
+					errs = append(errs, fmt.Sprintf("%s is not a supported parameter", describe(joinPath(path, k))))
+				}
+				continue
+			}
+			errs = append(errs, validateValue(joinPath(path, k), p, v[k])...)
+		}
+	}
+	return errs
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
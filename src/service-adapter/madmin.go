@@ -0,0 +1,226 @@
+/*
+ * Minio Cloud Storage, (C) 2019 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+const adminAPIPrefix = "/minio/admin/v3"
+
+// Error codes returned by the Minio admin API.
+const (
+	errNoSuchUser    = "XMinioAdminNoSuchUser"
+	errNoSuchPolicy  = "XMinioAdminNoSuchPolicy"
+	errGroupNotEmpty = "XMinioAdminGroupNotEmpty"
+	errNoSuchGroup   = "XMinioAdminNoSuchGroup"
+)
+
+// userInfo - details of a user returned by the Minio admin API.
+type userInfo struct {
+	PolicyName string   `json:"policyName,omitempty"`
+	Status     string   `json:"status"`
+	MemberOf   []string `json:"memberOf,omitempty"`
+}
+
+// adminClient - minimal client for the Minio admin API of an instance, used to
+// manage the users and policies handed out to the bindings.
+type adminClient struct {
+	minioClient
+}
+
+func newAdminClient(endpoint, accessKey, secretKey string) *adminClient {
+	return &adminClient{newMinioClient(endpoint, accessKey, secretKey)}
+}
+
+func (c *adminClient) do(method, api string, values url.Values, body []byte) ([]byte, error) {
+	b, status, err := c.execute(method, adminAPIPrefix+"/"+api, values, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		aerr := apiError{StatusCode: status}
+		json.Unmarshal(b, &aerr)
+		return nil, aerr
+	}
+	return b, nil
+}
+
+// AddUser - creates (or updates) the user with the given keys.
+func (c *adminClient) AddUser(accessKey, secretKey string) error {
+	b, err := json.Marshal(map[string]string{"secretKey": secretKey, "status": "enabled"})
+	if err != nil {
+		return err
+	}
+	// The admin API expects the secret key of the new user encrypted with the
+	// secret key of the caller.
+	b, err = encryptData(c.secretKey, b)
+	if err != nil {
+		return err
+	}
+	_, err = c.do(http.MethodPut, "add-user", url.Values{"accessKey": {accessKey}}, b)
+	return err
+}
+
+// RemoveUser - deletes the user.
+func (c *adminClient) RemoveUser(accessKey string) error {
+	_, err := c.do(http.MethodDelete, "remove-user", url.Values{"accessKey": {accessKey}}, nil)
+	return err
+}
+
+// UserInfo - returns the details of the user.
+func (c *adminClient) UserInfo(accessKey string) (info userInfo, err error) {
+	b, err := c.do(http.MethodGet, "user-info", url.Values{"accessKey": {accessKey}}, nil)
+	if err != nil {
+		return info, err
+	}
+	err = json.Unmarshal(b, &info)
+	return info, err
+}
+
+type groupMembers struct {
+	Group    string   `json:"group"`
+	Members  []string `json:"members"`
+	IsRemove bool     `json:"isRemove"`
+}
+
+func (c *adminClient) updateGroupMembers(g groupMembers) error {
+	b, err := json.Marshal(g)
+	if err != nil {
+		return err
+	}
+	_, err = c.do(http.MethodPut, "update-group-members", nil, b)
+	return err
+}
+
+// AddGroupMember - adds the user to the group, the group is created if needed.
+func (c *adminClient) AddGroupMember(group, accessKey string) error {
+	return c.updateGroupMembers(groupMembers{Group: group, Members: []string{accessKey}})
+}
+
+// RemoveGroupMember - removes the user from the group, the group is removed
+// once it has no members left.
+func (c *adminClient) RemoveGroupMember(group, accessKey string) error {
+	err := c.updateGroupMembers(groupMembers{Group: group, Members: []string{accessKey}, IsRemove: true})
+	if err != nil && !isAPIError(err, errNoSuchGroup) {
+		return err
+	}
+	err = c.updateGroupMembers(groupMembers{Group: group, IsRemove: true})
+	if err != nil && !isAPIError(err, errGroupNotEmpty) && !isAPIError(err, errNoSuchGroup) {
+		return err
+	}
+	return nil
+}
+
+// AddCannedPolicy - creates (or replaces) the policy with the given name.
+func (c *adminClient) AddCannedPolicy(name string, policy []byte) error {
+	_, err := c.do(http.MethodPut, "add-canned-policy", url.Values{"name": {name}}, policy)
+	return err
+}
+
+// RemoveCannedPolicy - deletes the policy with the given name.
+func (c *adminClient) RemoveCannedPolicy(name string) error {
+	_, err := c.do(http.MethodDelete, "remove-canned-policy", url.Values{"name": {name}}, nil)
+	return err
+}
+
+// SetUserPolicy - attaches the policy to the user.
+func (c *adminClient) SetUserPolicy(accessKey, policyName string) error {
+	values := url.Values{
+		"policyName":  {policyName},
+		"userOrGroup": {accessKey},
+		"isGroup":     {"false"},
+	}
+	_, err := c.do(http.MethodPut, "set-user-or-group-policy", values, nil)
+	return err
+}
+
+// encryptData - encrypts the data in the format expected by the Minio admin
+// API: salt(32) | algorithm(1) | nonce(8) | sio stream. The key is derived
+// from the password using PBKDF2 and the stream is sealed with AES-256-GCM.
+func encryptData(password string, data []byte) ([]byte, error) {
+	return encryptDataFrom(rand.Reader, password, data)
+}
+
+// encryptDataFrom - encrypts the data with the salt and the nonce read from
+// random.
+func encryptDataFrom(random io.Reader, password string, data []byte) ([]byte, error) {
+	const pbkdf2AESGCM = 0x02
+	salt := make([]byte, 32)
+	nonce := make([]byte, 8)
+	if _, err := io.ReadFull(random, salt); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(random, nonce); err != nil {
+		return nil, err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, 8192, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.Write(salt)
+	buf.WriteByte(pbkdf2AESGCM)
+	buf.Write(nonce)
+
+	// The payload is split into 16 KiB fragments, each fragment is sealed with
+	// the nonce and its sequence number, starting at 1. The associated data of
+	// the fragments is a flag marking the final one, followed by the tag sealed
+	// with sequence number 0 over no data.
+	const fragmentSize = 16 * 1024
+	fragmentNonce := make([]byte, aead.NonceSize())
+	copy(fragmentNonce, nonce)
+	ad := make([]byte, 1, 1+aead.Overhead())
+	ad = aead.Seal(ad, fragmentNonce, nil, nil)
+	for seq := uint32(1); ; seq++ {
+		n := len(data)
+		final := n <= fragmentSize
+		if !final {
+			n = fragmentSize
+		}
+		ad[0] = 0x00
+		if final {
+			ad[0] = 0x80
+		}
+		binary.LittleEndian.PutUint32(fragmentNonce[aead.NonceSize()-4:], seq)
+		buf.Write(aead.Seal(nil, fragmentNonce, data[:n], ad))
+		data = data[n:]
+		if final {
+			break
+		}
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,247 @@
+/*
+ * Minio Cloud Storage, (C) 2019 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// decryptData - decrypts data the way the Minio admin API does, following the
+// sio stream format. TestEncryptDataKnownAnswer checks the format against
+// madmin.
+func decryptData(password string, data []byte) ([]byte, error) {
+	if len(data) < 32+1+8 || data[32] != 0x02 {
+		return nil, errors.New("invalid header")
+	}
+	salt, nonce, data := data[:32], data[33:41], data[41:]
+	key, err := pbkdf2.Key(sha256.New, password, salt, 8192, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	fragmentNonce := make([]byte, aead.NonceSize())
+	copy(fragmentNonce, nonce)
+	ad := aead.Seal([]byte{0x00}, fragmentNonce, nil, nil)
+
+	const fragmentSize = 16*1024 + 16
+	var plaintext []byte
+	for seq := uint32(1); ; seq++ {
+		n := len(data)
+		final := n <= fragmentSize
+		if !final {
+			n = fragmentSize
+		}
+		ad[0] = 0x00
+		if final {
+			ad[0] = 0x80
+		}
+		binary.LittleEndian.PutUint32(fragmentNonce[aead.NonceSize()-4:], seq)
+		p, err := aead.Open(nil, fragmentNonce, data[:n], ad)
+		if err != nil {
+			return nil, err
+		}
+		plaintext = append(plaintext, p...)
+		data = data[n:]
+		if final {
+			return plaintext, nil
+		}
+	}
+}
+
+// Known answers of madmin EncryptData (fips build, sio-go v0.3.1), with the
+// salt 00..1f, the nonce a0..a7 and the password minio123.
+const (
+	knownAnswerAddUser    = `{"secretKey":"bindingsecret","status":"enabled"}`
+	knownAnswerAddUserHex = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f02a0a1a2a3a4a5a6a7" +
+		"06383db07aa956bebee231ada6b5be4277f7caef4f5cbec5a3e797d386789fbb6030f338a780a50daee3e09509d02edf776dfad604ef6c67c322b600d1c3d46c"
+	// 40000 bytes i%251, sealed in three fragments.
+	knownAnswerLargeLength = 40089
+	knownAnswerLargeSHA256 = "7be79e570778b6d6c116caf77cc7e12c159caff1872f76a1d8985f07a62a7242"
+)
+
+func TestEncryptDataKnownAnswer(t *testing.T) {
+	random := func() io.Reader {
+		b := make([]byte, 40)
+		for i := 0; i < 32; i++ {
+			b[i] = byte(i)
+		}
+		for i := 0; i < 8; i++ {
+			b[32+i] = byte(0xa0 + i)
+		}
+		return bytes.NewReader(b)
+	}
+	b, err := encryptDataFrom(random(), "minio123", []byte(knownAnswerAddUser))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(b) != knownAnswerAddUserHex {
+		t.Fatalf("unexpected ciphertext %x", b)
+	}
+
+	large := make([]byte, 40000)
+	for i := range large {
+		large[i] = byte(i % 251)
+	}
+	if b, err = encryptDataFrom(random(), "minio123", large); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(b)
+	if len(b) != knownAnswerLargeLength || hex.EncodeToString(sum[:]) != knownAnswerLargeSHA256 {
+		t.Fatalf("unexpected ciphertext of %d bytes, sha256 %x", len(b), sum)
+	}
+}
+
+func TestEncryptData(t *testing.T) {
+	for _, size := range []int{0, 1, 16 * 1024, 16*1024 + 1, 40 * 1024} {
+		data := bytes.Repeat([]byte{'x'}, size)
+		b, err := encryptData("secret", data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		plaintext, err := decryptData("secret", b)
+		if err != nil {
+			t.Fatalf("size %d: %s", size, err)
+		}
+		if !bytes.Equal(plaintext, data) {
+			t.Fatalf("size %d: decrypted data does not match", size)
+		}
+		if _, err = decryptData("other", b); err == nil {
+			t.Fatalf("size %d: decrypted with the wrong password", size)
+		}
+	}
+}
+
+// fakeAdmin - Minio admin API serving the users of an instance.
+type fakeAdmin struct {
+	secretKey string
+	mu        sync.Mutex
+	users     map[string]userInfo
+	secrets   map[string]string
+}
+
+func (a *fakeAdmin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=admin/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	fail := func(status int, code string) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(apiError{Code: code, Message: code})
+	}
+	accessKey := r.URL.Query().Get("accessKey")
+	switch strings.TrimPrefix(r.URL.Path, adminAPIPrefix+"/") {
+	case "add-user":
+		b, _ := ioutil.ReadAll(r.Body)
+		b, err := decryptData(a.secretKey, b)
+		if err != nil {
+			fail(http.StatusBadRequest, "XMinioAdminConfigBadJSON")
+			return
+		}
+		var user struct {
+			SecretKey string `json:"secretKey"`
+			Status    string `json:"status"`
+		}
+		if err = json.Unmarshal(b, &user); err != nil {
+			fail(http.StatusBadRequest, "XMinioAdminConfigBadJSON")
+			return
+		}
+		a.users[accessKey] = userInfo{Status: user.Status}
+		a.secrets[accessKey] = user.SecretKey
+	case "set-user-or-group-policy":
+		name := r.URL.Query().Get("userOrGroup")
+		info, ok := a.users[name]
+		if !ok || r.URL.Query().Get("isGroup") != "false" {
+			fail(http.StatusNotFound, errNoSuchUser)
+			return
+		}
+		info.PolicyName = r.URL.Query().Get("policyName")
+		a.users[name] = info
+	case "user-info":
+		info, ok := a.users[accessKey]
+		if !ok {
+			fail(http.StatusNotFound, errNoSuchUser)
+			return
+		}
+		json.NewEncoder(w).Encode(info)
+	case "remove-user":
+		if _, ok := a.users[accessKey]; !ok {
+			fail(http.StatusNotFound, errNoSuchUser)
+			return
+		}
+		delete(a.users, accessKey)
+		delete(a.secrets, accessKey)
+	default:
+		fail(http.StatusNotFound, "XMinioAdminAPINotFound")
+	}
+}
+
+func TestAdminClientUsers(t *testing.T) {
+	admin := &fakeAdmin{secretKey: "adminsecret", users: map[string]userInfo{}, secrets: map[string]string{}}
+	server := httptest.NewServer(admin)
+	defer server.Close()
+	c := newAdminClient(server.URL, "admin", "adminsecret")
+
+	if _, err := c.UserInfo("binding"); !isAPIError(err, errNoSuchUser) {
+		t.Fatalf("expected %s, got %v", errNoSuchUser, err)
+	}
+	if err := c.AddUser("binding", "bindingsecret"); err != nil {
+		t.Fatal(err)
+	}
+	if admin.secrets["binding"] != "bindingsecret" {
+		t.Fatalf("unexpected secret key %q", admin.secrets["binding"])
+	}
+	if err := c.SetUserPolicy("binding", "readwrite"); err != nil {
+		t.Fatal(err)
+	}
+	info, err := c.UserInfo("binding")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Status != "enabled" || info.PolicyName != "readwrite" {
+		t.Fatalf("unexpected user info %+v", info)
+	}
+	if err = c.RemoveUser("binding"); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.RemoveUser("binding"); !isAPIError(err, errNoSuchUser) {
+		t.Fatalf("expected %s, got %v", errNoSuchUser, err)
+	}
+}
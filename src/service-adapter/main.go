@@ -19,10 +19,8 @@ package main
 import (
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
 
 	"time"
@@ -38,11 +36,24 @@ import (
 const instancePrefix = "service-instance_"
 const tmpDir = "/tmp/minio/"
 
+// Port the minio server listens on.
+const minioPort = 9000
+
+// Names of the BOSH variables holding the generated AccessKey/SecretKey.
+const (
+	accessKeyVariable = "minio_accesskey"
+	secretKeyVariable = "minio_secretkey"
+)
+
 type route struct {
 	Name     string   `yaml:"name"`
-	Port     int      `yaml:"port"`
+	Port     int      `yaml:"port,omitempty"`
 	Interval string   `yaml:"registration_interval"`
 	Uris     []string `yaml:uris`
+
+	// Routes to the instances served over TLS.
+	TLSPort             int    `yaml:"tls_port,omitempty"`
+	ServerCertDomainSAN string `yaml:"server_cert_domain_san,omitempty"`
 }
 
 func fromPreviousManifestParameters(params map[interface{}]interface{}) map[string]interface{} {
@@ -57,7 +68,13 @@ func fromPreviousManifestParameters(params map[interface{}]interface{}) map[stri
 type adapter struct{}
 
 // GenerateManifest - generates BOSH manifest file.
-func (a adapter) GenerateManifest(serviceDeployment serviceadapter.ServiceDeployment, plan serviceadapter.Plan, requestParams serviceadapter.RequestParameters, previousManifest *bosh.BoshManifest, previousPlan *serviceadapter.Plan, secrets serviceadapter.ManifestSecrets) (generateManifest serviceadapter.GenerateManifestOutput, err error) {
+func (a adapter) GenerateManifest(serviceDeployment serviceadapter.ServiceDeployment, plan serviceadapter.Plan, requestParams serviceadapter.RequestParameters, previousManifest *bosh.BoshManifest, previousPlan *serviceadapter.Plan, secrets serviceadapter.ManifestSecrets) (serviceadapter.GenerateManifestOutput, error) {
+	return a.generateManifestAt(time.Now(), serviceDeployment, plan, requestParams, previousManifest, previousPlan, secrets)
+}
+
+// generateManifestAt - generates the manifest at now, which sets the expiry of
+// the rotated credentials and the validity of the certificates.
+func (a adapter) generateManifestAt(now time.Time, serviceDeployment serviceadapter.ServiceDeployment, plan serviceadapter.Plan, requestParams serviceadapter.RequestParameters, previousManifest *bosh.BoshManifest, previousPlan *serviceadapter.Plan, secrets serviceadapter.ManifestSecrets) (generateManifest serviceadapter.GenerateManifestOutput, err error) {
 	pid := os.Getpid()
 	outputFile := fmt.Sprintf(tmpDir+"output-%d.yml", pid)
 	manifest := generateManifest.Manifest
@@ -69,67 +86,190 @@ func (a adapter) GenerateManifest(serviceDeployment serviceadapter.ServiceDeploy
 
 	var params map[string]interface{}
 	var instances int
+	var pools []int
+	var rotate bool
+	var addPool int
+	var addingPool bool
+	var migrate bool
+	var migrationState string
 	if previousManifest == nil || previousManifest.Name == "" {
 		// Previous manifest is not available implies that a fresh instance is getting created.
-		// Instance can't be created with out -c config option providing AccessKey/SecretKey.
-		if requestParams["parameters"] == nil {
-			f.WriteString(`AcessKey/SecretKey configuration not provided.\n`)
-			return generateManifest, errors.New(`Acesskey/Secretkey configuration not provided.\n`)
+		params = requestParams.ArbitraryParams()
+		if err = validateParameters(plan, params, false); err != nil {
+			f.WriteString(err.Error())
+			return generateManifest, err
 		}
 
 		// Fresh instance is getting created.
 
 		// Number of instances, configured in the tile.
-		instances, err = strconv.Atoi(plan.Properties["instances"].(string))
+		instances, err = planInstances(plan)
 		if err != nil {
 			f.WriteString(`Unable to parse "instances"`)
-			return generateManifest, errors.New(fmt.Sprintf(`Unable to parse "instances": %s`, err.Error()))
+			return generateManifest, err
+		}
+		if instances != 1 {
+			pools = []int{instances}
 		}
-		params = requestParams["parameters"].(map[string]interface{})
 	} else {
 		// Previous manifest available implies that we might be updating the plan or config.
+		// Parameters of the update are merged over the previous ones.
+		previousParams, _ := previousManifest.Properties["parameters"].(map[interface{}]interface{})
+		params = fromPreviousManifestParameters(previousParams)
 		if requestParams["parameters"] != nil {
-			params = requestParams["parameters"].(map[string]interface{})
-			if params["gateway"] != nil {
-				return generateManifest, errors.New(`"gateway" can be specified only during instance creation`)
+			update := requestParams.ArbitraryParams()
+			if params, err = mergeParameters(params, update); err != nil {
+				f.WriteString(err.Error())
+				return generateManifest, err
+			}
+			if err = validateParameters(plan, update, true); err != nil {
+				f.WriteString(err.Error())
+				return generateManifest, err
+			}
+			rotate, _ = update["rotate_credentials"].(bool)
+			if rotate && update["secretkey"] != nil {
+				f.WriteString(`"secretkey" can not be specified with "rotate_credentials"`)
+				return generateManifest, errors.New(`"secretkey" can not be specified with "rotate_credentials"`)
 			}
-		} else {
-			params = fromPreviousManifestParameters(previousManifest.Properties["parameters"].(map[interface{}]interface{}))
+			delete(params, "rotate_credentials")
+			if pool, ok := update["add_server_pool"].(float64); ok {
+				addPool, addingPool = int(pool), true
+			}
+			delete(params, "add_server_pool")
+			migrate, _ = update["migrate_to_erasure"].(bool)
+			delete(params, "migrate_to_erasure")
+		}
+		previousState := previousMigrationState(previousManifest)
+		if migrationState, err = nextMigrationState(previousState, migrate); err != nil {
+			f.WriteString(err.Error())
+			return generateManifest, err
+		}
+		gateway, _ := params["gateway"].(string)
+		if rotate && gatewayBackends[gateway].backendKeys {
+			f.WriteString(fmt.Sprintf(`"rotate_credentials" can not be specified for %s gateways, their credentials are the ones of the %s backend`, gateway, gatewayBackends[gateway].name))
+			return generateManifest, fmt.Errorf(`"rotate_credentials" can not be specified for %s gateways, their credentials are the ones of the %s backend`, gateway, gatewayBackends[gateway].name)
+		}
+		// Changes of plan which would lose data are refused, the ones which
+		// restart the instances are logged by the broker.
+		if previousPlan != nil {
+			warnings, err := checkPlanChange(*previousPlan, plan, gateway, previousState == "" && migrationState != "")
+			for _, warning := range warnings {
+				fmt.Fprintln(os.Stderr, warning)
+				f.WriteString(warning + "\n")
+			}
+			if err != nil {
+				f.WriteString(err.Error())
+				return generateManifest, err
+			}
+		}
+		// Rotation replaces the secret key with a generated one.
+		if rotate {
+			delete(params, "secretkey")
+		}
+		// Number of instances will always be same as previous deployment,
+		// unless a server pool is added to the erasure deployment or the
+		// single instance is migrated to erasure.
+		switch previousState {
+		case "":
+			instances = previousManifest.InstanceGroups[0].Instances
+			if migrationState == migrationMirroring {
+				if instances != 1 || gateway != "" {
+					f.WriteString(`"migrate_to_erasure" can be specified only for single instance deployments`)
+					return generateManifest, errors.New(`"migrate_to_erasure" can be specified only for single instance deployments`)
+				}
+				instances, err = planInstances(plan)
+				if err != nil {
+					f.WriteString(`Unable to parse "instances"`)
+					return generateManifest, err
+				}
+				break
+			}
+			if pools, err = previousServerPools(previousManifest); err != nil {
+				f.WriteString(err.Error())
+				return generateManifest, err
+			}
+		case migrationMirroring:
+			erasure := findInstanceGroup(previousManifest, erasureInstanceGroup)
+			if erasure == nil {
+				f.WriteString(fmt.Sprintf("instance group %s not found in the previous manifest", erasureInstanceGroup))
+				return generateManifest, fmt.Errorf("instance group %s not found in the previous manifest", erasureInstanceGroup)
+			}
+			instances = erasure.Instances
+			if migrationState == migrationMigrated {
+				if err = checkMirrorCompleted(previousManifest, secrets); err != nil {
+					f.WriteString(err.Error())
+					return generateManifest, err
+				}
+				pools = []int{instances}
+			}
+		default:
+			instances = previousManifest.InstanceGroups[0].Instances
+			if pools, err = previousServerPools(previousManifest); err != nil {
+				f.WriteString(err.Error())
+				return generateManifest, err
+			}
+		}
+		if addingPool {
+			if len(pools) == 0 || migrationState == migrationMirroring {
+				f.WriteString(`"add_server_pool" can be specified only for erasure deployments`)
+				return generateManifest, errors.New(`"add_server_pool" can be specified only for erasure deployments`)
+			}
+			if err = validPoolSize(addPool); err != nil {
+				f.WriteString(err.Error())
+				return generateManifest, err
+			}
+			pools = append(pools, addPool)
+			instances = serverPoolsInstances(pools)
 		}
-		// Number of instances will always be same as previous deployment.
-		instances = previousManifest.InstanceGroups[0].Instances
 	}
 
-	plan.InstanceGroups[0].Instances = instances
-
-	// If the number of instances is configured as 1 then we allow fs, gcs, azure.
-	// If the number of instances is not 1 then we allow only erasure.
-	deploymentType := "fs"
-	if plan.InstanceGroups[0].Instances != 1 {
-		deploymentType = "erasure"
+	// Google credentials can be given as a JSON object.
+	if err = normalizeGoogleCredentials(params); err != nil {
+		f.WriteString(err.Error())
+		return generateManifest, err
 	}
-
-	if params["gateway"] != nil {
-		deploymentType = params["gateway"].(string)
+	if err = normalizeAzureCredentials(params, previousManifest != nil && previousManifest.Name != ""); err != nil {
+		f.WriteString(err.Error())
+		return generateManifest, err
 	}
 
-	if deploymentType == "gcs" {
-		if params["googlecredentials"] == nil {
-			f.WriteString(`googlecredentials should be provided for GCS`)
-			return generateManifest, errors.New(`googlecredentials should be provided for GCS`)
-		}
+	// Secrets provided with -c config option are not kept in plaintext in the
+	// manifest, they are stored as ODB-managed secrets.
+	generateManifest.ODBManagedSecrets = serviceadapter.ODBManagedSecrets{}
+	rotation, err := rotateCredentials(rotate, params, plan, &manifest, previousManifest, secrets, generateManifest.ODBManagedSecrets, now)
+	if err != nil {
+		f.WriteString(err.Error())
+		return generateManifest, err
 	}
+	params, err = manageSecrets(params, secrets, generateManifest.ODBManagedSecrets)
+	if err != nil {
+		f.WriteString(err.Error())
+		return generateManifest, err
+	}
+
+	plan.InstanceGroups[0].Instances = instances
+
+	// If the number of instances is configured as 1 then we allow fs and the gateways.
+	// If the number of instances is not 1 then we allow only erasure.
+	gateway, _ := params["gateway"].(string)
+	deploymentType := deploymentTypeOf(plan.InstanceGroups[0].Instances, gateway)
+
 	var minioJobType string
 	switch deploymentType {
 	case "fs", "erasure":
 		minioJobType = "minio-server"
-	case "azure":
-		minioJobType = "minio-azure"
-	case "gcs":
-		minioJobType = "minio-gcs"
 	default:
-		f.WriteString(fmt.Sprintf(`"%s" deployment type is not supported`, deploymentType))
-		return generateManifest, errors.New(fmt.Sprintf(`"%s" deployment type is not supported`, deploymentType))
+		backend, ok := gatewayBackends[deploymentType]
+		if !ok {
+			f.WriteString(fmt.Sprintf(`"%s" deployment type is not supported`, deploymentType))
+			return generateManifest, errors.New(fmt.Sprintf(`"%s" deployment type is not supported`, deploymentType))
+		}
+		minioJobType = backend.job
+	}
+	// Each gateway backend requires its own parameters.
+	if err = validateGatewayParameters(gateway, params, generateManifest.ODBManagedSecrets); err != nil {
+		f.WriteString(err.Error())
+		return generateManifest, err
 	}
 
 	deploymentInstanceGroupsToJobs := map[string][]string{"minio-ig": []string{minioJobType, "route_registrar", "bpm"}}
@@ -163,12 +303,52 @@ func (a adapter) GenerateManifest(serviceDeployment serviceadapter.ServiceDeploy
 		}
 	}
 
+	// Instance groups of the migration of a single instance to erasure.
+	if migrationState != "" {
+		m, err := migrateInstanceGroups(migrationState, &manifest, previousManifest)
+		if err != nil {
+			f.WriteString(err.Error())
+			return generateManifest, err
+		}
+		mprops["migration"] = m
+	}
+	// Erasure deployments get the errand mirroring the data of the single
+	// instance, when the release provides it, since post-deploy errands are
+	// configured by plan. The errand does nothing unless migrating.
+	if deploymentType == "erasure" && releasesProvideJob(serviceDeployment.Releases, mirrorErrand) {
+		errand, err := mirrorErrandInstanceGroup(plan, serviceDeployment.Releases)
+		if err != nil {
+			f.WriteString(err.Error())
+			return generateManifest, err
+		}
+		manifest.InstanceGroups = append(manifest.InstanceGroups, errand)
+	} else if migrationState == migrationMirroring {
+		f.WriteString(fmt.Sprintf("%s job, required by the migration, is not provided by the releases", mirrorErrand))
+		return generateManifest, fmt.Errorf("%s job, required by the migration, is not provided by the releases", mirrorErrand)
+	}
+
 	mprops["parameters"] = params
 
+	// Settings of the gateway backend.
+	if gateway != "" {
+		mprops["gateway"] = gatewaySettings(gateway, params)
+	}
+
+	// Instances of the erasure deployment by server pool, the minio job serves
+	// every pool.
+	if len(pools) > 0 {
+		mprops["server_pools"] = pools
+	}
+
 	if pprops["pcf_tile_version"] != nil {
 		mprops["pcf_tile_version"] = pprops["pcf_tile_version"]
 	}
 
+	// Bindings configuration of the plan, used by CreateBinding.
+	if pprops["bindings"] != nil {
+		mprops["bindings"] = pprops["bindings"]
+	}
+
 	domain := fmt.Sprintf("%s.%s", strings.TrimPrefix(manifest.Name, instancePrefix), pprops["domain"].(string))
 	subdomain := params["subdomain"]
 	if subdomain != nil {
@@ -176,19 +356,79 @@ func (a adapter) GenerateManifest(serviceDeployment serviceadapter.ServiceDeploy
 		domain = fmt.Sprintf("%s.storage.%s", subdomain.(string), pprops["domain"].(string))
 	}
 	mprops["domain"] = domain
+
+	// Certificate provided with -c config option takes precedence, otherwise
+	// plans with a CA get a certificate generated by BOSH. Instances with a
+	// certificate are reached over TLS by the gorouter.
+	r := route{Name: "route", Interval: "20s", Uris: []string{domain}}
+	if params["tls_certificate"] != nil || params["tls_private_key"] != nil {
+		certificate, _ := generateManifest.ODBManagedSecrets["tls_certificate"].(string)
+		key, _ := generateManifest.ODBManagedSecrets["tls_private_key"].(string)
+		if err = validateCertificate(certificate, key, domain, now); err != nil {
+			f.WriteString(err.Error())
+			return generateManifest, err
+		}
+		mprops["tls"] = map[string]string{
+			"certificate": params["tls_certificate"].(string),
+			"private_key": params["tls_private_key"].(string),
+		}
+	} else if ca, ok := pprops[tlsCAProperty].(string); ok && ca != "" {
+		manifest.Variables = append(manifest.Variables, generatedCertificate(ca, domain))
+		for i, job := range manifest.InstanceGroups[0].Jobs {
+			if job.Name == minioJobType {
+				manifest.InstanceGroups[0].Jobs[i] = job.AddCustomProviderDefinition(tlsAddressLink, "address", nil)
+			}
+		}
+		mprops["tls"] = certificateProperties(tlsCertificateVariable)
+	}
+	if mprops["tls"] != nil {
+		r.TLSPort = minioPort
+		r.ServerCertDomainSAN = domain
+	} else {
+		r.Port = minioPort
+	}
 	mprops["route_registrar"] = map[string][]route{
-		"routes": []route{
-			{
-				"route", 9000, "20s",
-				[]string{domain},
-			},
-		},
+		"routes": []route{r},
+	}
+	// AccessKey/SecretKey provided with -c config option take precedence, otherwise
+	// they are generated by BOSH (CredHub) and referenced in the manifest.
+	// Root keys of azure gateways are the name and key of the storage account,
+	// as read by the minio-azure job.
+	accessKeyParameter, secretKeyParameter := "accesskey", "secretkey"
+	if gateway == "azure" {
+		accessKeyParameter, secretKeyParameter = "azure_storage_account", "azure_storage_key"
 	}
 	credential := make(map[string]string)
-	credential["accesskey"] = params["accesskey"].(string)
-	credential["secretkey"] = params["secretkey"].(string)
-	if params["googlecredentials"] != nil {
-		credential["googlecredentials"] = params["googlecredentials"].(string)
+	if accessKey, ok := params[accessKeyParameter].(string); ok {
+		credential["accesskey"] = accessKey
+	} else {
+		credential["accesskey"] = "((" + accessKeyVariable + "))"
+		manifest.Variables = append(manifest.Variables, bosh.Variable{Name: accessKeyVariable, Type: "password"})
+	}
+	if secretKey, ok := params[secretKeyParameter].(string); ok {
+		credential["secretkey"] = secretKey
+	} else {
+		// Rotated secret key is generated in a new variable.
+		variable := secretKeyVariable
+		if rotation.SecretKeyVariable != "" {
+			variable = rotation.SecretKeyVariable
+		}
+		credential["secretkey"] = "((" + variable + "))"
+		manifest.Variables = append(manifest.Variables, bosh.Variable{Name: variable, Type: "password"})
+	}
+	// Previous secret key stays valid until it expires.
+	if rotation.PreviousSecretKey != "" {
+		credential["previous_secretkey"] = rotation.PreviousSecretKey
+		credential["previous_secretkey_expires_at"] = rotation.ExpiresAt
+	}
+	if rotation != (credentialRotation{}) {
+		mprops["credential_rotation"] = rotation
+	}
+	// Secret parameters of the gateway backend.
+	for _, p := range secretParameters {
+		if _, ok := credential[p]; !ok && params[p] != nil && gatewayBackends[gateway].accepts(p) {
+			credential[p] = params[p].(string)
+		}
 	}
 	mprops["credential"] = credential
 	manifest.Properties = mprops
@@ -202,25 +442,11 @@ func (a adapter) GenerateManifest(serviceDeployment serviceadapter.ServiceDeploy
 	return generateManifest, nil
 }
 
-// CreateBinding - Not implemented
-func (a adapter) CreateBinding(bindingID string, deploymentTopology bosh.BoshVMs, manifest bosh.BoshManifest, requestParams serviceadapter.RequestParameters, secrets serviceadapter.ManifestSecrets, address serviceadapter.DNSAddresses) (binding serviceadapter.Binding, err error) {
-	return binding, errors.New("not supported")
-}
-
-// DeleteBinding - Not implemented
-func (a adapter) DeleteBinding(bindingID string, deploymentTopology bosh.BoshVMs, manifest bosh.BoshManifest, requestParams serviceadapter.RequestParameters, secrets serviceadapter.ManifestSecrets) error {
-	return errors.New("not supported")
-}
-
 // DashboardUrl - returns URL that looks like https://351c705a-6210-4b5e-b853-472fc8cd7646.sys.pie-27.cfplatformeng.com
 func (a adapter) DashboardUrl(instanceID string, plan serviceadapter.Plan, manifest bosh.BoshManifest) (url serviceadapter.DashboardUrl, err error) {
 	return serviceadapter.DashboardUrl{"https://" + manifest.Properties["domain"].(string)}, nil
 }
 
-func (a adapter) GeneratePlanSchema(plan serviceadapter.Plan) (schema serviceadapter.PlanSchema, err error) {
-	return schema, errors.New("not supported")
-}
-
 func cleanupTmpDir() {
 	if err := os.Mkdir(tmpDir, 0700); err != nil {
 		if !strings.Contains(err.Error(), "exists") {
@@ -242,30 +468,10 @@ func cleanupTmpDir() {
 }
 
 func main() {
-	pid := os.Getpid()
 	cleanupTmpDir()
-	inputFile := fmt.Sprintf(tmpDir+"input-%d.json", pid)
-	input, err := os.Create(inputFile)
-	if err != nil {
-		fmt.Println("Unable to open input.json", err.Error())
-		os.Exit(1)
-	}
-
-	b, err := ioutil.ReadAll(os.Stdin)
-	if err != nil {
-		fmt.Println("Unable to read from os.Stdin", err.Error())
-		os.Exit(1)
-	}
-
-	fmt.Fprint(input, string(b))
-	input.Close()
-	input, err = os.Open(inputFile)
-	if err != nil {
-		fmt.Println("Unable to open input.json", err.Error())
-		os.Exit(1)
-	}
-	os.Stdin = input
 
+	// Input of the command, read from stdin by serviceadapter, is not stored
+	// for debugging as it carries the secrets of the instance.
 	handler := serviceadapter.CommandLineHandler{
 		ManifestGenerator:     adapter{},
 		Binder:                adapter{},
@@ -0,0 +1,129 @@
+/*
+ * Minio Cloud Storage, (C) 2019 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+	yaml "gopkg.in/yaml.v2"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden manifests of testdata")
+
+func testServiceDeployment() serviceadapter.ServiceDeployment {
+	return serviceadapter.ServiceDeployment{
+		DeploymentName: instancePrefix + "3a8c5ba6",
+		Releases: serviceadapter.ServiceReleases{{
+			Name:    "minio",
+			Version: "1.0.0",
+			Jobs: []string{"minio-server", "minio-azure", "minio-gcs", "minio-s3", "minio-nas",
+				"minio-hdfs", "minio-b2", "minio-oss", "minio-mirror", "route_registrar", "bpm"},
+		}},
+		Stemcell: serviceadapter.Stemcell{OS: "ubuntu-xenial", Version: "315.36"},
+	}
+}
+
+func testServicePlan(instances string) serviceadapter.Plan {
+	return serviceadapter.Plan{
+		Properties: serviceadapter.Properties{
+			"instances":  instances,
+			"deployment": "cf",
+			"domain":     "sys.example.com",
+		},
+		InstanceGroups: []serviceadapter.InstanceGroup{{
+			Name:               "minio-ig",
+			VMType:             "large",
+			PersistentDiskType: "10240",
+			Instances:          1,
+			Networks:           []string{"default"},
+			AZs:                []string{"z1"},
+		}},
+	}
+}
+
+// generateTestManifest - runs GenerateManifest, previous is the previous
+// manifest as read back from YAML by ODB.
+func generateTestManifest(t *testing.T, plan serviceadapter.Plan, params map[string]interface{}, previous *bosh.BoshManifest, previousPlan *serviceadapter.Plan, secrets serviceadapter.ManifestSecrets) (serviceadapter.GenerateManifestOutput, error) {
+	return generateTestManifestAt(t, time.Now(), plan, params, previous, previousPlan, secrets)
+}
+
+// generateTestManifestAt - runs GenerateManifest at now.
+func generateTestManifestAt(t *testing.T, now time.Time, plan serviceadapter.Plan, params map[string]interface{}, previous *bosh.BoshManifest, previousPlan *serviceadapter.Plan, secrets serviceadapter.ManifestSecrets) (serviceadapter.GenerateManifestOutput, error) {
+	if err := os.MkdirAll(tmpDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	requestParams := serviceadapter.RequestParameters{}
+	if params != nil {
+		requestParams["parameters"] = params
+	}
+	return adapter{}.generateManifestAt(now, testServiceDeployment(), plan, requestParams, previous, previousPlan, secrets)
+}
+
+// reloadManifest - returns the manifest as ODB passes it back as the previous
+// manifest.
+func reloadManifest(t *testing.T, manifest bosh.BoshManifest) *bosh.BoshManifest {
+	b, err := yaml.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var previous bosh.BoshManifest
+	if err = yaml.Unmarshal(b, &previous); err != nil {
+		t.Fatal(err)
+	}
+	return &previous
+}
+
+// previousSecrets - returns the ODB-managed secrets of output as ODB resolves
+// them for the next GenerateManifest.
+func previousSecrets(output serviceadapter.GenerateManifestOutput) serviceadapter.ManifestSecrets {
+	secrets := serviceadapter.ManifestSecrets{}
+	for name, value := range output.ODBManagedSecrets {
+		secrets[odbSecretReference(name)] = value.(string)
+	}
+	return secrets
+}
+
+// assertGoldenManifest - compares the manifest with testdata/name.yml, run the
+// tests with -update to regenerate the golden manifests.
+func assertGoldenManifest(t *testing.T, name string, manifest bosh.BoshManifest) {
+	t.Helper()
+	b, err := yaml.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := filepath.Join("testdata", name+".yml")
+	if *updateGolden {
+		if err = ioutil.WriteFile(golden, b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, expected) {
+		t.Errorf("manifest differs from %s:\n%s", golden, b)
+	}
+}
@@ -0,0 +1,176 @@
+/*
+ * Minio Cloud Storage, (C) 2019 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+// Migration of a single instance (fs) to an erasure deployment, opted in with
+// the "migrate_to_erasure" parameter along with the change to an erasure plan.
+// Each step is recorded in the "migration" manifest property:
+//
+//   - mirroring: the single instance is moved to the minio-fs-ig instance group,
+//     it keeps serving the route while the minio-mirror errand copies its data to
+//     the instances of the new minio-erasure-ig instance group.
+//   - migrated: once mirrored, "migrate_to_erasure" retires minio-fs-ig and the
+//     erasure instances are moved back to the minio-ig instance group.
+//
+// The errand records the completion of the mirroring with the object
+// minio-mirror/completed on the single instance, the migration is refused
+// until then.
+const (
+	migrationMirroring = "mirroring"
+	migrationMigrated  = "migrated"
+)
+
+const (
+	fsInstanceGroup      = "minio-fs-ig"
+	erasureInstanceGroup = "minio-erasure-ig"
+	mirrorErrand         = "minio-mirror"
+)
+
+const (
+	mirrorCompletedBucket = "minio-mirror"
+	mirrorCompletedObject = "completed"
+)
+
+// migration - the "migration" manifest property, the errand mirrors the data
+// of the instances of Source to the instances of Target.
+type migration struct {
+	State  string `yaml:"state"`
+	Source string `yaml:"source_instance_group,omitempty"`
+	Target string `yaml:"target_instance_group,omitempty"`
+}
+
+func previousMigrationState(previousManifest *bosh.BoshManifest) string {
+	m, _ := previousManifest.Properties["migration"].(map[interface{}]interface{})
+	state, _ := m["state"].(string)
+	return state
+}
+
+// nextMigrationState - returns the state of the migration after the update,
+// migrate is set when "migrate_to_erasure" is specified.
+func nextMigrationState(state string, migrate bool) (string, error) {
+	switch {
+	case state == "" && migrate:
+		return migrationMirroring, nil
+	case state == migrationMirroring && migrate:
+		return migrationMigrated, nil
+	case state == migrationMigrated && migrate:
+		return "", fmt.Errorf("instance is already migrated to erasure")
+	}
+	return state, nil
+}
+
+// checkMirrorCompleted - returns an error unless the errand recorded the
+// completion of the mirroring on the single instance of the previous manifest.
+func checkMirrorCompleted(previousManifest *bosh.BoshManifest, secrets serviceadapter.ManifestSecrets) error {
+	inst, err := instanceFromManifest(*previousManifest, secrets)
+	if err != nil {
+		return err
+	}
+	completed, err := newS3Client(inst.endpoint(), inst.accessKey, inst.secretKey).ObjectExists(mirrorCompletedBucket, mirrorCompletedObject)
+	if err != nil {
+		return fmt.Errorf("unable to check whether the %s errand completed: %s", mirrorErrand, err)
+	}
+	if !completed {
+		return fmt.Errorf(`data of the instance is not mirrored yet, run the %s errand before "migrate_to_erasure"`, mirrorErrand)
+	}
+	return nil
+}
+
+func findInstanceGroup(manifest *bosh.BoshManifest, name string) *bosh.InstanceGroup {
+	for i := range manifest.InstanceGroups {
+		if manifest.InstanceGroups[i].Name == name {
+			return &manifest.InstanceGroups[i]
+		}
+	}
+	return nil
+}
+
+func releasesProvideJob(releases serviceadapter.ServiceReleases, job string) bool {
+	for _, release := range releases {
+		for _, j := range release.Jobs {
+			if j == job {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// mirrorErrandInstanceGroup - returns the errand instance group which mirrors
+// the data during the migration, it is run as a post-deploy errand of the
+// erasure plans and does nothing without the "migration" manifest property or
+// once migrated.
+func mirrorErrandInstanceGroup(plan serviceadapter.Plan, releases serviceadapter.ServiceReleases) (bosh.InstanceGroup, error) {
+	errand := plan.InstanceGroups[0]
+	errand.Name = mirrorErrand
+	errand.Instances = 1
+	errand.Lifecycle = "errand"
+	errand.PersistentDiskType = ""
+	errand.MigratedFrom = nil
+	groups, err := serviceadapter.GenerateInstanceGroupsWithNoProperties(
+		[]serviceadapter.InstanceGroup{errand}, releases, "os-stemcell",
+		map[string][]string{mirrorErrand: []string{mirrorErrand}})
+	if err != nil {
+		return bosh.InstanceGroup{}, err
+	}
+	return groups[0], nil
+}
+
+// migrateInstanceGroups - lays out the instance groups of the manifest for the
+// state of the migration. manifest.InstanceGroups[0] is the erasure instance
+// group, generated as minio-ig.
+func migrateInstanceGroups(state string, manifest *bosh.BoshManifest, previousManifest *bosh.BoshManifest) (*migration, error) {
+	erasure := &manifest.InstanceGroups[0]
+	switch state {
+	case migrationMirroring:
+		// The single instance keeps its persistent disk and the route until
+		// the data is mirrored.
+		fs := findInstanceGroup(previousManifest, fsInstanceGroup)
+		if fs == nil {
+			fs = findInstanceGroup(previousManifest, erasure.Name)
+		}
+		if fs == nil {
+			return nil, fmt.Errorf("instance group %s not found in the previous manifest", erasure.Name)
+		}
+		fsGroup := *fs
+		if fsGroup.Name != fsInstanceGroup {
+			fsGroup.MigratedFrom = []bosh.Migration{{Name: fsGroup.Name}}
+			fsGroup.Name = fsInstanceGroup
+		}
+		var jobs []bosh.Job
+		for _, job := range erasure.Jobs {
+			if job.Name != "route_registrar" {
+				jobs = append(jobs, job)
+			}
+		}
+		erasure.Jobs = jobs
+		erasure.Name = erasureInstanceGroup
+		manifest.InstanceGroups = append(manifest.InstanceGroups, fsGroup)
+		return &migration{State: state, Source: fsInstanceGroup, Target: erasureInstanceGroup}, nil
+	case migrationMigrated:
+		erasure.MigratedFrom = []bosh.Migration{{Name: erasureInstanceGroup}}
+		return &migration{State: state}, nil
+	}
+	return nil, nil
+}
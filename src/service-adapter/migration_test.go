@@ -0,0 +1,124 @@
+/*
+ * Minio Cloud Storage, (C) 2019 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+func instanceGroupNames(manifest bosh.BoshManifest) (names []string) {
+	for _, ig := range manifest.InstanceGroups {
+		names = append(names, ig.Name)
+	}
+	return names
+}
+
+func TestMigrationToErasure(t *testing.T) {
+	// Single instance, reached through its route while mirroring.
+	completed := false
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead || r.URL.Path != "/minio-mirror/completed" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=root/") {
+			t.Errorf("unexpected authorization %s", r.Header.Get("Authorization"))
+		}
+		if !completed {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	defer func(transport http.RoundTripper) { http.DefaultTransport = transport }(http.DefaultTransport)
+	http.DefaultTransport = server.Client().Transport
+
+	single, erasure := testServicePlan("1"), testServicePlan("4")
+	output, err := generateTestManifest(t, single, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := strings.Join(instanceGroupNames(output.Manifest), " "); names != "minio-ig" {
+		t.Fatalf("unexpected instance groups %s", names)
+	}
+
+	output, err = generateTestManifest(t, erasure, map[string]interface{}{"migrate_to_erasure": true}, reloadManifest(t, output.Manifest), &single, previousSecrets(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := strings.Join(instanceGroupNames(output.Manifest), " "); names != "minio-erasure-ig minio-fs-ig minio-mirror" {
+		t.Fatalf("unexpected instance groups %s", names)
+	}
+
+	previous := reloadManifest(t, output.Manifest)
+	previous.Properties["domain"] = strings.TrimPrefix(server.URL, "https://")
+	secrets := serviceadapter.ManifestSecrets{"minio_accesskey": "root", "minio_secretkey": "root-secret"}
+	_, err = generateTestManifest(t, erasure, map[string]interface{}{"migrate_to_erasure": true}, previous, &erasure, secrets)
+	if err == nil || !strings.Contains(err.Error(), "not mirrored yet") {
+		t.Fatalf("expected the migration to wait for the errand, got %v", err)
+	}
+
+	completed = true
+	output, err = generateTestManifest(t, erasure, map[string]interface{}{"migrate_to_erasure": true}, previous, &erasure, secrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := strings.Join(instanceGroupNames(output.Manifest), " "); names != "minio-ig minio-mirror" {
+		t.Fatalf("unexpected instance groups %s", names)
+	}
+	if instances := output.Manifest.InstanceGroups[0].Instances; instances != 4 {
+		t.Fatalf("expected 4 instances, got %d", instances)
+	}
+}
+
+func TestErasureWithoutMigration(t *testing.T) {
+	// Plans run the errand after every deployment, it is part of plain
+	// erasure deployments when the release provides it.
+	output, err := generateTestManifest(t, testServicePlan("4"), nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := strings.Join(instanceGroupNames(output.Manifest), " "); names != "minio-ig minio-mirror" {
+		t.Fatalf("unexpected instance groups %s", names)
+	}
+	if output.Manifest.Properties["migration"] != nil {
+		t.Fatalf("unexpected migration %v", output.Manifest.Properties["migration"])
+	}
+
+	deployment := testServiceDeployment()
+	deployment.Releases[0].Jobs = []string{"minio-server", "route_registrar", "bpm"}
+	output, err = adapter{}.GenerateManifest(deployment, testServicePlan("4"), serviceadapter.RequestParameters{}, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := strings.Join(instanceGroupNames(output.Manifest), " "); names != "minio-ig" {
+		t.Fatalf("unexpected instance groups %s", names)
+	}
+
+	// Single instances do not run the errand.
+	output, err = generateTestManifest(t, testServicePlan("1"), nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := strings.Join(instanceGroupNames(output.Manifest), " "); names != "minio-ig" {
+		t.Fatalf("unexpected instance groups %s", names)
+	}
+}
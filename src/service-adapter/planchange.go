@@ -0,0 +1,203 @@
+/*
+ * Minio Cloud Storage, (C) 2019 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+// deploymentTypeOf - returns the deployment type of an instance: the gateway
+// when one is configured, otherwise fs for a single instance and erasure for
+// more instances.
+func deploymentTypeOf(instances int, gateway string) string {
+	if gateway != "" {
+		return gateway
+	}
+	if instances != 1 {
+		return "erasure"
+	}
+	return "fs"
+}
+
+// planLayout - the part of a plan which plan changes are checked against.
+type planLayout struct {
+	deploymentType string
+	instances      int
+	vmType         string
+	diskType       string
+
+	// Set when the change starts the migration of a single instance to erasure.
+	migrating bool
+}
+
+func newPlanLayout(plan serviceadapter.Plan, gateway string) (layout planLayout, err error) {
+	if layout.instances, err = planInstances(plan); err != nil {
+		return layout, err
+	}
+	layout.deploymentType = deploymentTypeOf(layout.instances, gateway)
+	layout.vmType = plan.InstanceGroups[0].VMType
+	layout.diskType = plan.InstanceGroups[0].PersistentDiskType
+	return layout, nil
+}
+
+// hasLocalData - whether the objects are stored on the persistent disks of the
+// instances, instead of the backend of a gateway.
+func (l planLayout) hasLocalData() bool {
+	return l.deploymentType == "fs" || l.deploymentType == "erasure"
+}
+
+// deploymentKind - returns fs, erasure, or gateway for the gateway backends.
+func deploymentKind(deploymentType string) string {
+	if deploymentType == "fs" || deploymentType == "erasure" {
+		return deploymentType
+	}
+	return "gateway"
+}
+
+// deploymentTypeChanges - changes of deployment kind on plan change, changes
+// with a reason are refused, unless migration allows them along with the
+// "migrate_to_erasure" parameter. The gateway of an instance can not be
+// changed, gateways stay gateways whatever the plan.
+var deploymentTypeChanges = []struct {
+	from, to  string
+	reason    string
+	migration bool
+}{
+	{"fs", "fs", "", false},
+	{"fs", "erasure", `the data of a single instance is moved to an erasure deployment only with the "migrate_to_erasure" parameter`, true},
+	{"erasure", "fs", "the data of an erasure deployment can not be moved to a single instance", false},
+	{"erasure", "erasure", "", false},
+	{"gateway", "gateway", "", false},
+}
+
+// planChangeRule - checks a change from the previous plan, returns the
+// warnings about the change and the reason to refuse it.
+type planChangeRule func(previous, current planLayout) (warnings []string, reason string)
+
+var planChangeRules = []planChangeRule{
+	checkDeploymentType,
+	checkInstances,
+	checkPersistentDisk,
+	checkVMType,
+}
+
+func checkDeploymentType(previous, current planLayout) ([]string, string) {
+	for _, change := range deploymentTypeChanges {
+		if change.from == deploymentKind(previous.deploymentType) && change.to == deploymentKind(current.deploymentType) {
+			if change.migration && current.migrating {
+				return nil, ""
+			}
+			return nil, change.reason
+		}
+	}
+	return nil, fmt.Sprintf("%s deployment can not be changed to %s", previous.deploymentType, current.deploymentType)
+}
+
+func checkInstances(previous, current planLayout) ([]string, string) {
+	switch {
+	case current.instances == previous.instances, current.deploymentType != previous.deploymentType:
+		// Changes of deployment type are checked by checkDeploymentType.
+		return nil, ""
+	case current.instances < previous.instances:
+		return nil, fmt.Sprintf("the plan has fewer instances (%d) than the previous plan (%d), data would be lost", current.instances, previous.instances)
+	case !current.hasLocalData():
+		return nil, "gateways run a single instance"
+	default:
+		return nil, `instances can not be added to an erasure deployment with a plan change, use the "add_server_pool" parameter`
+	}
+}
+
+func checkPersistentDisk(previous, current planLayout) ([]string, string) {
+	if current.diskType == previous.diskType || !current.hasLocalData() {
+		return nil, ""
+	}
+	if current.diskType == "" {
+		return nil, "the plan has no persistent disk, data would be lost"
+	}
+	previousSize, ok := diskSize(previous.diskType)
+	currentSize, currentOk := diskSize(current.diskType)
+	if !ok || !currentOk {
+		return []string{fmt.Sprintf("unable to compare persistent disk types %q and %q, instances are restarted one by one", previous.diskType, current.diskType)}, ""
+	}
+	if currentSize < previousSize {
+		return nil, fmt.Sprintf("the persistent disk type %q is smaller than %q, data would be lost", current.diskType, previous.diskType)
+	}
+	return []string{fmt.Sprintf("persistent disks are resized from %q to %q, instances are restarted one by one", previous.diskType, current.diskType)}, ""
+}
+
+func checkVMType(previous, current planLayout) ([]string, string) {
+	if current.vmType == previous.vmType {
+		return nil, ""
+	}
+	return []string{fmt.Sprintf("VM type changes from %q to %q, instances are restarted one by one", previous.vmType, current.vmType)}, ""
+}
+
+// Persistent disk types named after their size, like 10240 (in MB, as in Ops
+// Manager) or 10GB.
+var diskSizePattern = regexp.MustCompile(`^(?i)(\d+)\s*(m|mb|g|gb|t|tb)?$`)
+
+// diskSize - returns the size in MB of a persistent disk type named after its size.
+func diskSize(diskType string) (int, bool) {
+	m := diskSizePattern.FindStringSubmatch(strings.TrimSpace(diskType))
+	if m == nil {
+		return 0, false
+	}
+	size, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, false
+	}
+	switch strings.ToLower(m[2]) {
+	case "g", "gb":
+		size *= 1024
+	case "t", "tb":
+		size *= 1024 * 1024
+	}
+	return size, true
+}
+
+// checkPlanChange - checks the change from previousPlan to plan against
+// planChangeRules, returns the warnings about the change, and an error with
+// every reason to refuse it. migrating is set when the change starts the
+// migration of a single instance to erasure.
+func checkPlanChange(previousPlan, plan serviceadapter.Plan, gateway string, migrating bool) (warnings []string, err error) {
+	previous, err := newPlanLayout(previousPlan, gateway)
+	if err != nil {
+		return nil, err
+	}
+	current, err := newPlanLayout(plan, gateway)
+	if err != nil {
+		return nil, err
+	}
+	current.migrating = migrating
+	var reasons []string
+	for _, rule := range planChangeRules {
+		w, reason := rule(previous, current)
+		warnings = append(warnings, w...)
+		if reason != "" {
+			reasons = append(reasons, reason)
+		}
+	}
+	if len(reasons) > 0 {
+		return warnings, fmt.Errorf("plan change refused: %s", strings.Join(reasons, "; "))
+	}
+	return warnings, nil
+}
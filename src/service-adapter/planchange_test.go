@@ -0,0 +1,151 @@
+/*
+ * Minio Cloud Storage, (C) 2019 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+func TestDeploymentTypeChanges(t *testing.T) {
+	testCases := []struct {
+		from, to  string
+		gateway   string
+		migrating bool
+		refused   string
+	}{
+		{"1", "1", "", false, ""},
+		{"1", "4", "", false, `only with the "migrate_to_erasure" parameter`},
+		{"1", "4", "", true, ""},
+		{"4", "1", "", false, "can not be moved to a single instance"},
+		{"4", "4", "", false, ""},
+		{"1", "1", "azure", false, ""},
+		{"1", "4", "azure", false, "gateways run a single instance"},
+		{"1", "1", "gcs", false, ""},
+		{"1", "4", "gcs", false, "gateways run a single instance"},
+	}
+	for _, tc := range testCases {
+		_, err := checkPlanChange(testServicePlan(tc.from), testServicePlan(tc.to), tc.gateway, tc.migrating)
+		if tc.refused == "" && err != nil {
+			t.Errorf("%s %s to %s: unexpected refusal %s", tc.gateway, tc.from, tc.to, err)
+		}
+		if tc.refused != "" && (err == nil || !strings.Contains(err.Error(), tc.refused)) {
+			t.Errorf("%s %s to %s: expected refusal %q, got %v", tc.gateway, tc.from, tc.to, tc.refused, err)
+		}
+	}
+}
+
+func TestCheckPlanChange(t *testing.T) {
+	testCases := []struct {
+		name     string
+		change   func(plan *serviceadapter.Plan)
+		refused  string
+		warnings []string
+	}{
+		{"no change", func(p *serviceadapter.Plan) {}, "", nil},
+		{"fewer instances", func(p *serviceadapter.Plan) { p.Properties["instances"] = "2" }, "fewer instances (2) than the previous plan (4)", nil},
+		{"more instances", func(p *serviceadapter.Plan) { p.Properties["instances"] = "8" }, "add_server_pool", nil},
+		{"smaller disk", func(p *serviceadapter.Plan) { p.InstanceGroups[0].PersistentDiskType = "5GB" }, `"5GB" is smaller than "10240"`, nil},
+		{"no disk", func(p *serviceadapter.Plan) { p.InstanceGroups[0].PersistentDiskType = "" }, "no persistent disk", nil},
+		{"larger disk", func(p *serviceadapter.Plan) { p.InstanceGroups[0].PersistentDiskType = "20GB" }, "",
+			[]string{`persistent disks are resized from "10240" to "20GB"`}},
+		{"unparsable disk", func(p *serviceadapter.Plan) { p.InstanceGroups[0].PersistentDiskType = "fast-ssd" }, "",
+			[]string{`unable to compare persistent disk types "10240" and "fast-ssd"`}},
+		{"vm type", func(p *serviceadapter.Plan) { p.InstanceGroups[0].VMType = "xlarge" }, "",
+			[]string{`VM type changes from "large" to "xlarge"`}},
+		{"vm type and disk", func(p *serviceadapter.Plan) {
+			p.InstanceGroups[0].VMType = "xlarge"
+			p.InstanceGroups[0].PersistentDiskType = "1TB"
+		}, "", []string{`persistent disks are resized from "10240" to "1TB"`, `VM type changes from "large" to "xlarge"`}},
+		{"fewer instances and smaller disk", func(p *serviceadapter.Plan) {
+			p.Properties["instances"] = "2"
+			p.InstanceGroups[0].PersistentDiskType = "5GB"
+		}, `fewer instances (2) than the previous plan (4), data would be lost; the persistent disk type "5GB" is smaller`, nil},
+	}
+	for _, tc := range testCases {
+		plan := testServicePlan("4")
+		tc.change(&plan)
+		warnings, err := checkPlanChange(testServicePlan("4"), plan, "", false)
+		if tc.refused == "" && err != nil {
+			t.Errorf("%s: unexpected refusal %s", tc.name, err)
+		}
+		if tc.refused != "" && (err == nil || !strings.HasPrefix(err.Error(), "plan change refused: ") || !strings.Contains(err.Error(), tc.refused)) {
+			t.Errorf("%s: expected refusal %q, got %v", tc.name, tc.refused, err)
+		}
+		if len(warnings) != len(tc.warnings) {
+			t.Errorf("%s: expected warnings %q, got %q", tc.name, tc.warnings, warnings)
+			continue
+		}
+		for i := range warnings {
+			if !strings.HasPrefix(warnings[i], tc.warnings[i]) {
+				t.Errorf("%s: expected warning %q, got %q", tc.name, tc.warnings[i], warnings[i])
+			}
+		}
+	}
+
+	// Disks of gateways hold no data.
+	plan := testServicePlan("1")
+	plan.InstanceGroups[0].PersistentDiskType = ""
+	if _, err := checkPlanChange(testServicePlan("1"), plan, "nas", false); err != nil {
+		t.Errorf("gateway without disk: unexpected refusal %s", err)
+	}
+}
+
+func TestDiskSize(t *testing.T) {
+	testCases := []struct {
+		diskType string
+		size     int
+		ok       bool
+	}{
+		{"10240", 10240, true},
+		{"10GB", 10240, true},
+		{"10 gb", 10240, true},
+		{"1T", 1024 * 1024, true},
+		{"512M", 512, true},
+		{"fast-ssd", 0, false},
+		{"", 0, false},
+	}
+	for _, tc := range testCases {
+		size, ok := diskSize(tc.diskType)
+		if size != tc.size || ok != tc.ok {
+			t.Errorf("%q: expected %d %v, got %d %v", tc.diskType, tc.size, tc.ok, size, ok)
+		}
+	}
+}
+
+func TestPlanChangeOfInstance(t *testing.T) {
+	single, erasure := testServicePlan("1"), testServicePlan("4")
+	output, err := generateTestManifest(t, single, map[string]interface{}{"gateway": "nas", "nas_path": "/mnt/nas"}, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = generateTestManifest(t, erasure, nil, reloadManifest(t, output.Manifest), &single, previousSecrets(output))
+	if err == nil || !strings.Contains(err.Error(), "gateways run a single instance") {
+		t.Fatalf("expected the plan change of the gateway to be refused, got %v", err)
+	}
+
+	output, err = generateTestManifest(t, single, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = generateTestManifest(t, erasure, nil, reloadManifest(t, output.Manifest), &single, previousSecrets(output))
+	if err == nil || !strings.Contains(err.Error(), `only with the "migrate_to_erasure" parameter`) {
+		t.Fatalf("expected the plan change of the single instance to be refused, got %v", err)
+	}
+}
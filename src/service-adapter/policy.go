@@ -0,0 +1,142 @@
+/*
+ * Minio Cloud Storage, (C) 2019 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Permissions which can be requested for a binding with the "permission"
+// bind parameter.
+const (
+	permissionReadOnly  = "read-only"
+	permissionWriteOnly = "write-only"
+	permissionReadWrite = "read-write"
+	permissionAdmin     = "admin"
+)
+
+// S3 actions allowed for each permission, on the buckets and on the objects.
+var permissionActions = map[string]struct {
+	bucket []string
+	object []string
+}{
+	permissionReadOnly: {
+		bucket: []string{"s3:ListBucket"},
+		object: []string{"s3:GetObject"},
+	},
+	permissionWriteOnly: {
+		object: []string{"s3:PutObject", "s3:AbortMultipartUpload", "s3:ListMultipartUploadParts"},
+	},
+	permissionReadWrite: {
+		bucket: []string{"s3:ListBucket", "s3:ListBucketMultipartUploads"},
+		object: []string{"s3:GetObject", "s3:PutObject", "s3:DeleteObject", "s3:AbortMultipartUpload", "s3:ListMultipartUploadParts"},
+	},
+}
+
+type policyStatement struct {
+	Effect    string                         `json:"Effect"`
+	Action    []string                       `json:"Action"`
+	Resource  []string                       `json:"Resource,omitempty"`
+	Condition map[string]map[string][]string `json:"Condition,omitempty"`
+}
+
+type policyDocument struct {
+	Version   string            `json:"Version"`
+	Statement []policyStatement `json:"Statement"`
+}
+
+func validPermissions() string {
+	permissions := []string{permissionAdmin}
+	for p := range permissionActions {
+		permissions = append(permissions, p)
+	}
+	sort.Strings(permissions)
+	return strings.Join(permissions, ", ")
+}
+
+// permissionPolicy - returns the policy document granting the permission. The
+// policy is narrowed down to the given buckets and to the objects under prefix,
+// when they are set. With no buckets the policy applies to all the buckets.
+func permissionPolicy(permission string, buckets []string, prefix string) (policy policyDocument, err error) {
+	policy.Version = "2012-10-17"
+	if permission == permissionAdmin {
+		if len(buckets) > 0 || prefix != "" {
+			return policy, errors.New(`"buckets" and "prefix" can not be used with "admin" permission`)
+		}
+		policy.Statement = []policyStatement{
+			{Effect: "Allow", Action: []string{"admin:*"}},
+			{Effect: "Allow", Action: []string{"s3:*"}, Resource: []string{"arn:aws:s3:::*"}},
+		}
+		return policy, nil
+	}
+	actions, ok := permissionActions[permission]
+	if !ok {
+		return policy, fmt.Errorf(`"%s" permission is not supported, valid permissions are: %s`, permission, validPermissions())
+	}
+
+	for _, bucket := range buckets {
+		if bucket == "" || strings.ContainsAny(bucket, "/*") {
+			return policy, fmt.Errorf(`"%s" is not a valid bucket name`, bucket)
+		}
+	}
+	scoped := len(buckets) > 0 || prefix != ""
+	if len(buckets) == 0 {
+		buckets = []string{"*"}
+	}
+	var bucketResources, objectResources []string
+	for _, bucket := range buckets {
+		bucketResources = append(bucketResources, "arn:aws:s3:::"+bucket)
+		objectResources = append(objectResources, "arn:aws:s3:::"+bucket+"/"+prefix+"*")
+	}
+
+	// Listing the buckets of the instance is allowed only when the policy is
+	// not narrowed down.
+	if !scoped && permission != permissionWriteOnly {
+		policy.Statement = append(policy.Statement, policyStatement{
+			Effect:   "Allow",
+			Action:   []string{"s3:ListAllMyBuckets"},
+			Resource: []string{"arn:aws:s3:::*"},
+		})
+	}
+	policy.Statement = append(policy.Statement, policyStatement{
+		Effect:   "Allow",
+		Action:   []string{"s3:GetBucketLocation"},
+		Resource: bucketResources,
+	})
+	if len(actions.bucket) > 0 {
+		statement := policyStatement{
+			Effect:   "Allow",
+			Action:   actions.bucket,
+			Resource: bucketResources,
+		}
+		if prefix != "" {
+			statement.Condition = map[string]map[string][]string{
+				"StringLike": {"s3:prefix": {prefix + "*"}},
+			}
+		}
+		policy.Statement = append(policy.Statement, statement)
+	}
+	policy.Statement = append(policy.Statement, policyStatement{
+		Effect:   "Allow",
+		Action:   actions.object,
+		Resource: objectResources,
+	})
+	return policy, nil
+}
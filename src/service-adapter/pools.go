@@ -0,0 +1,72 @@
+/*
+ * Minio Cloud Storage, (C) 2019 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
+)
+
+// Erasure set sizes supported by the minio server, every server pool is made
+// of erasure sets of the same size. Each instance has a single drive.
+const (
+	minErasureSetSize = 2
+	maxErasureSetSize = 16
+)
+
+// validPoolSize - returns an error unless instances can be split in erasure
+// sets of a size supported by the minio server.
+func validPoolSize(instances int) error {
+	for size := maxErasureSetSize; size >= minErasureSetSize; size-- {
+		if instances >= size && instances%size == 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf(`server pool of %d instances can not be split in erasure sets of %d to %d instances`, instances, minErasureSetSize, maxErasureSetSize)
+}
+
+// previousServerPools - returns the number of instances of each server pool of
+// the previous manifest. Instances of the server pools are in the same instance
+// group, ordered by pool. Erasure deployments created before server pools have
+// a single pool, other deployments have none.
+func previousServerPools(previousManifest *bosh.BoshManifest) ([]int, error) {
+	previous, ok := previousManifest.Properties["server_pools"].([]interface{})
+	if !ok {
+		if instances := previousManifest.InstanceGroups[0].Instances; instances != 1 {
+			return []int{instances}, nil
+		}
+		return nil, nil
+	}
+	var pools []int
+	for _, pool := range previous {
+		instances, ok := pool.(int)
+		if !ok {
+			return nil, fmt.Errorf(`Unable to parse "server_pools" of the previous manifest: %v`, previous)
+		}
+		pools = append(pools, instances)
+	}
+	return pools, nil
+}
+
+// serverPoolsInstances - returns the number of instances of all the pools.
+func serverPoolsInstances(pools []int) (instances int) {
+	for _, pool := range pools {
+		instances += pool
+	}
+	return instances
+}
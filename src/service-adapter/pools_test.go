@@ -0,0 +1,104 @@
+/*
+ * Minio Cloud Storage, (C) 2019 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+func TestValidPoolSize(t *testing.T) {
+	testCases := []struct {
+		instances int
+		valid     bool
+	}{
+		{-4, false},
+		{0, false},
+		{1, false},
+		{2, true},
+		{3, true},
+		{4, true},
+		{16, true},
+		{17, false},
+		{19, false},
+		{32, true},
+		{34, true},
+	}
+	for _, tc := range testCases {
+		if err := validPoolSize(tc.instances); (err == nil) != tc.valid {
+			t.Errorf("%d instances: expected valid %v, got %v", tc.instances, tc.valid, err)
+		}
+	}
+}
+
+func TestServerPools(t *testing.T) {
+	plan := testServicePlan("4")
+	output, err := generateTestManifest(t, plan, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGoldenManifest(t, "erasure-create", output.Manifest)
+
+	output, err = generateTestManifest(t, plan, map[string]interface{}{"add_server_pool": float64(8)}, reloadManifest(t, output.Manifest), &plan, previousSecrets(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGoldenManifest(t, "erasure-add-server-pool", output.Manifest)
+
+	output, err = generateTestManifest(t, plan, map[string]interface{}{"subdomain": "files"}, reloadManifest(t, output.Manifest), &plan, previousSecrets(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGoldenManifest(t, "erasure-update-after-pool", output.Manifest)
+	if instances := output.Manifest.InstanceGroups[0].Instances; instances != 12 {
+		t.Fatalf("expected 12 instances, got %d", instances)
+	}
+	if pools := output.Manifest.Properties["server_pools"]; !reflect.DeepEqual(pools, []int{4, 8}) {
+		t.Fatalf("expected server pools [4 8], got %v", pools)
+	}
+}
+
+func TestAddServerPoolRefused(t *testing.T) {
+	plan := testServicePlan("4")
+	erasure, err := generateTestManifest(t, plan, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	single := testServicePlan("1")
+	fs, err := generateTestManifest(t, single, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		name     string
+		plan     serviceadapter.Plan
+		previous serviceadapter.GenerateManifestOutput
+		pool     float64
+	}{
+		{"single instance", single, fs, 4},
+		{"pool of 17", plan, erasure, 17},
+		{"pool of 1", plan, erasure, 1},
+	}
+	for _, tc := range testCases {
+		_, err := generateTestManifest(t, tc.plan, map[string]interface{}{"add_server_pool": tc.pool}, reloadManifest(t, tc.previous.Manifest), &tc.plan, previousSecrets(tc.previous))
+		if err == nil {
+			t.Errorf("%s: expected add_server_pool to be refused", tc.name)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+/*
+ * Minio Cloud Storage, (C) 2019 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Plan property holding how long the previous secret key stays valid after a
+// rotation, in seconds or like "24h".
+const rotationGracePeriodProperty = "credential_rotation_grace_period"
+
+const defaultRotationGracePeriod = 24 * time.Hour
+
+// Name of the ODB-managed secret holding the previous secret key, when it was
+// not generated by BOSH.
+const previousSecretKeySecret = "previous_secretkey"
+
+// credentialRotation - state of the secret key rotation, kept in the
+// "credential_rotation" manifest property. Once rotated, the secret key is the
+// BOSH variable SecretKeyVariable and the previous secret key is referenced by
+// PreviousSecretKey until ExpiresAt.
+type credentialRotation struct {
+	SecretKeyVariable string `yaml:"secretkey_variable,omitempty"`
+	PreviousSecretKey string `yaml:"previous_secretkey,omitempty"`
+	ExpiresAt         string `yaml:"expires_at,omitempty"`
+}
+
+func previousRotation(previousManifest *bosh.BoshManifest) (rotation credentialRotation, err error) {
+	if previousManifest == nil || previousManifest.Properties["credential_rotation"] == nil {
+		return rotation, nil
+	}
+	b, err := yaml.Marshal(previousManifest.Properties["credential_rotation"])
+	if err != nil {
+		return rotation, err
+	}
+	err = yaml.Unmarshal(b, &rotation)
+	return rotation, err
+}
+
+// boshVariable - returns the name of the BOSH variable when value references
+// a variable of the deployment, like ((minio_secretkey)).
+func boshVariable(value string) (string, bool) {
+	if !isReference(value) {
+		return "", false
+	}
+	name := strings.TrimSuffix(strings.TrimPrefix(value, "(("), "))")
+	if strings.Contains(name, "/") || strings.HasPrefix(name, serviceadapter.ODBSecretPrefix+":") {
+		return "", false
+	}
+	return name, true
+}
+
+// keepPreviousSecretKey - returns the reference to the previous secret key in
+// the new manifest. Secret keys generated by BOSH stay declared as variables,
+// other ones are resolved from previousSecrets and kept as an ODB-managed secret.
+func keepPreviousSecretKey(value string, manifest *bosh.BoshManifest, previousSecrets serviceadapter.ManifestSecrets, odbSecrets serviceadapter.ODBManagedSecrets) (string, error) {
+	if name, ok := boshVariable(value); ok {
+		manifest.Variables = append(manifest.Variables, bosh.Variable{Name: name, Type: "password"})
+		return value, nil
+	}
+	secret := resolveSecret(value, previousSecrets)
+	if secret == "" {
+		return "", fmt.Errorf("unable to find the value of %s in the previous secrets", value)
+	}
+	odbSecrets[previousSecretKeySecret] = secret
+	return odbSecretReference(previousSecretKeySecret), nil
+}
+
+// rotateCredentials - returns the rotation state of the new manifest. With
+// rotate set, the secret key is replaced by a new BOSH generated one and the
+// current secret key stays valid for the grace period of the plan. A secret
+// key provided with params ends the rotation.
+func rotateCredentials(rotate bool, params map[string]interface{}, plan serviceadapter.Plan, manifest *bosh.BoshManifest, previousManifest *bosh.BoshManifest, previousSecrets serviceadapter.ManifestSecrets, odbSecrets serviceadapter.ODBManagedSecrets, now time.Time) (rotation credentialRotation, err error) {
+	previous, err := previousRotation(previousManifest)
+	if err != nil {
+		return rotation, err
+	}
+	if !rotate {
+		if params["secretkey"] != nil {
+			return rotation, nil
+		}
+		rotation.SecretKeyVariable = previous.SecretKeyVariable
+		if previous.PreviousSecretKey == "" {
+			return rotation, nil
+		}
+		expiresAt, err := time.Parse(time.RFC3339, previous.ExpiresAt)
+		if err != nil || !now.Before(expiresAt) {
+			return rotation, nil
+		}
+		rotation.ExpiresAt = previous.ExpiresAt
+		rotation.PreviousSecretKey, err = keepPreviousSecretKey(previous.PreviousSecretKey, manifest, previousSecrets, odbSecrets)
+		return rotation, err
+	}
+
+	if previousManifest == nil || previousManifest.Name == "" {
+		return rotation, errors.New(`"rotate_credentials" can be specified only during instance update`)
+	}
+	gracePeriod := defaultRotationGracePeriod
+	if plan.Properties[rotationGracePeriodProperty] != nil {
+		if gracePeriod, err = parseDuration(rotationGracePeriodProperty, plan.Properties[rotationGracePeriodProperty]); err != nil {
+			return rotation, err
+		}
+	}
+	rotation.SecretKeyVariable = fmt.Sprintf("%s_%d", secretKeyVariable, now.Unix())
+	if gracePeriod <= 0 {
+		return rotation, nil
+	}
+	credential, _ := previousManifest.Properties["credential"].(map[interface{}]interface{})
+	current, _ := credential["secretkey"].(string)
+	if current == "" {
+		return rotation, errors.New(`"secretkey" not found in the previous manifest`)
+	}
+	rotation.ExpiresAt = now.Add(gracePeriod).UTC().Format(time.RFC3339)
+	rotation.PreviousSecretKey, err = keepPreviousSecretKey(current, manifest, previousSecrets, odbSecrets)
+	return rotation, err
+}
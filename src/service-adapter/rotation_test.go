@@ -0,0 +1,147 @@
+/*
+ * Minio Cloud Storage, (C) 2019 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+func manifestVariables(manifest bosh.BoshManifest) (names []string) {
+	for _, variable := range manifest.Variables {
+		names = append(names, variable.Name)
+	}
+	return names
+}
+
+func TestRotateCredentials(t *testing.T) {
+	created := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	rotated := created.Add(time.Hour)
+	rotatedVariable := fmt.Sprintf("minio_secretkey_%d", rotated.Unix())
+
+	testCases := []struct {
+		name        string
+		params      map[string]interface{}
+		gracePeriod interface{}
+		expiresAt   time.Time
+		secretKey   string
+		previous    string
+		variables   []string
+		secrets     serviceadapter.ODBManagedSecrets
+	}{
+		{
+			name:      "generated secret key",
+			expiresAt: rotated.Add(24 * time.Hour),
+			secretKey: "((minio_secretkey))",
+			previous:  "((minio_secretkey))",
+			variables: []string{"minio_accesskey", "minio_secretkey"},
+			secrets:   serviceadapter.ODBManagedSecrets{},
+		},
+		{
+			name:        "provided secret key",
+			params:      map[string]interface{}{"accesskey": "admin", "secretkey": "provided-secret-key"},
+			gracePeriod: "2h",
+			expiresAt:   rotated.Add(2 * time.Hour),
+			secretKey:   "((odb_secret:secretkey))",
+			previous:    "((odb_secret:previous_secretkey))",
+			variables:   nil,
+			secrets:     serviceadapter.ODBManagedSecrets{previousSecretKeySecret: "provided-secret-key"},
+		},
+	}
+	for _, tc := range testCases {
+		plan := testServicePlan("1")
+		if tc.gracePeriod != nil {
+			plan.Properties[rotationGracePeriodProperty] = tc.gracePeriod
+		}
+		output, err := generateTestManifestAt(t, created, plan, tc.params, nil, nil, nil)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+		credential := output.Manifest.Properties["credential"].(map[string]string)
+		if credential["secretkey"] != tc.secretKey {
+			t.Fatalf("%s: unexpected credential %v", tc.name, credential)
+		}
+		secrets := previousSecrets(output)
+		secrets["minio_secretkey"] = "generated-secret-key"
+
+		// Rotation keeps the current secret key as the previous one.
+		output, err = generateTestManifestAt(t, rotated, plan, map[string]interface{}{"rotate_credentials": true}, reloadManifest(t, output.Manifest), &plan, secrets)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+		expected := map[string]string{
+			"accesskey":                     credential["accesskey"],
+			"secretkey":                     "((" + rotatedVariable + "))",
+			"previous_secretkey":            tc.previous,
+			"previous_secretkey_expires_at": tc.expiresAt.Format(time.RFC3339),
+		}
+		assertRotation(t, tc.name+" rotated", output, expected, append(tc.variables, rotatedVariable), tc.secrets)
+
+		// Previous secret key stays valid until it expires.
+		secrets = previousSecrets(output)
+		output, err = generateTestManifestAt(t, tc.expiresAt.Add(-time.Minute), plan, map[string]interface{}{"subdomain": "files"}, reloadManifest(t, output.Manifest), &plan, secrets)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+		assertRotation(t, tc.name+" within the grace period", output, expected, append(tc.variables, rotatedVariable), tc.secrets)
+
+		secrets = previousSecrets(output)
+		output, err = generateTestManifestAt(t, tc.expiresAt, plan, nil, reloadManifest(t, output.Manifest), &plan, secrets)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+		delete(expected, "previous_secretkey")
+		delete(expected, "previous_secretkey_expires_at")
+		var variables []string
+		for _, variable := range tc.variables {
+			if variable != "minio_secretkey" {
+				variables = append(variables, variable)
+			}
+		}
+		assertRotation(t, tc.name+" expired", output, expected, append(variables, rotatedVariable), serviceadapter.ODBManagedSecrets{})
+	}
+}
+
+// assertRotation - checks the credential, the BOSH variables and the
+// ODB-managed secrets of the rotation, besides the provided keys.
+func assertRotation(t *testing.T, name string, output serviceadapter.GenerateManifestOutput, credential map[string]string, variables []string, secrets serviceadapter.ODBManagedSecrets) {
+	t.Helper()
+	if c := output.Manifest.Properties["credential"]; !reflect.DeepEqual(c, credential) {
+		t.Errorf("%s: expected credential %v, got %v", name, credential, c)
+	}
+	v := manifestVariables(output.Manifest)
+	sort.Strings(v)
+	sort.Strings(variables)
+	if !reflect.DeepEqual(v, variables) {
+		t.Errorf("%s: expected variables %v, got %v", name, variables, v)
+	}
+	odbSecrets := serviceadapter.ODBManagedSecrets{}
+	for key, value := range output.ODBManagedSecrets {
+		if key != "accesskey" && key != "secretkey" {
+			odbSecrets[key] = value
+		}
+	}
+	if !reflect.DeepEqual(odbSecrets, secrets) {
+		t.Errorf("%s: expected secrets %v, got %v", name, secrets, odbSecrets)
+	}
+}
@@ -0,0 +1,134 @@
+/*
+ * Minio Cloud Storage, (C) 2019 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+)
+
+// Error codes returned by the S3 API.
+const (
+	errNoSuchBucket            = "NoSuchBucket"
+	errNoSuchTagSet            = "NoSuchTagSet"
+	errBucketAlreadyOwnedByYou = "BucketAlreadyOwnedByYou"
+	errBucketAlreadyExists     = "BucketAlreadyExists"
+)
+
+// s3Client - minimal client for the S3 API of an instance, used to manage the
+// buckets created for the bindings.
+type s3Client struct {
+	minioClient
+}
+
+func newS3Client(endpoint, accessKey, secretKey string) *s3Client {
+	return &s3Client{newMinioClient(endpoint, accessKey, secretKey)}
+}
+
+func (c *s3Client) do(method, path string, values url.Values, header http.Header, body []byte) ([]byte, error) {
+	b, status, err := c.execute(method, path, values, header, body)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK && status != http.StatusNoContent {
+		serr := apiError{StatusCode: status}
+		xml.Unmarshal(b, &serr)
+		return nil, serr
+	}
+	return b, nil
+}
+
+// MakeBucket - creates the bucket.
+func (c *s3Client) MakeBucket(bucket string) error {
+	_, err := c.do(http.MethodPut, "/"+bucket, nil, nil, nil)
+	return err
+}
+
+// RemoveBucket - deletes the bucket, along with all its objects when force is set.
+func (c *s3Client) RemoveBucket(bucket string, force bool) error {
+	header := http.Header{}
+	if force {
+		header.Set("X-Minio-Force-Delete", "true")
+	}
+	_, err := c.do(http.MethodDelete, "/"+bucket, nil, header, nil)
+	return err
+}
+
+type tag struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+type tagging struct {
+	XMLName xml.Name `xml:"Tagging"`
+	TagSet  []tag    `xml:"TagSet>Tag"`
+}
+
+// ObjectExists - returns whether the object exists in the bucket.
+func (c *s3Client) ObjectExists(bucket, object string) (bool, error) {
+	_, status, err := c.execute(http.MethodHead, "/"+bucket+"/"+object, nil, nil, nil)
+	if err != nil {
+		return false, err
+	}
+	switch status {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, apiError{StatusCode: status}
+}
+
+// SetBucketTags - replaces the tags of the bucket.
+func (c *s3Client) SetBucketTags(bucket string, tags map[string]string) error {
+	var t tagging
+	for k, v := range tags {
+		t.TagSet = append(t.TagSet, tag{k, v})
+	}
+	b, err := xml.Marshal(t)
+	if err != nil {
+		return err
+	}
+	sum := md5.Sum(b)
+	header := http.Header{}
+	header.Set("Content-Md5", base64.StdEncoding.EncodeToString(sum[:]))
+	_, err = c.do(http.MethodPut, "/"+bucket, url.Values{"tagging": {""}}, header, b)
+	return err
+}
+
+// BucketTags - returns the tags of the bucket, a bucket without tags has none.
+func (c *s3Client) BucketTags(bucket string) (map[string]string, error) {
+	b, err := c.do(http.MethodGet, "/"+bucket, url.Values{"tagging": {""}}, nil, nil)
+	if isAPIError(err, errNoSuchTagSet) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var t tagging
+	if err = xml.Unmarshal(b, &t); err != nil {
+		return nil, err
+	}
+	tags := make(map[string]string)
+	for _, tag := range t.TagSet {
+		tags[tag.Key] = tag.Value
+	}
+	return tags, nil
+}
@@ -0,0 +1,283 @@
+/*
+ * Minio Cloud Storage, (C) 2019 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+const jsonSchemaDraft = "http://json-schema.org/draft-04/schema#"
+
+// Minimum length of the keys accepted by the minio server.
+const (
+	minAccessKeyLength = 3
+	minSecretKeyLength = 8
+)
+
+const dnsLabelPattern = `^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`
+
+// Parameters which can be specified only during instance creation.
+var immutableParameters = []string{"gateway"}
+
+// instanceParameters - returns the JSON schema of the parameters of cf
+// create-service (update is false) and cf update-service (update is true).
+// Gateways are offered only for the plans with a single instance, the gateway
+// of an instance can not be changed once created.
+func instanceParameters(instances int, update bool) map[string]interface{} {
+	properties := map[string]interface{}{
+		"accesskey": map[string]interface{}{
+			"type":        "string",
+			"description": "Access key of the instance, generated when not specified, it is the access key of the backend of s3, b2 and oss gateways",
+			"minLength":   minAccessKeyLength,
+		},
+		"secretkey": map[string]interface{}{
+			"type":        "string",
+			"description": "Secret key of the instance, generated when not specified, it is the secret key of the backend of s3, b2 and oss gateways",
+			"minLength":   minSecretKeyLength,
+		},
+		"tls_certificate": map[string]interface{}{
+			"type":        "string",
+			"description": "PEM encoded certificate of the instance domain, along with its intermediate certificates, instead of a certificate generated by BOSH",
+		},
+		"tls_private_key": map[string]interface{}{
+			"type":        "string",
+			"description": "PEM encoded private key of tls_certificate",
+		},
+		"subdomain": map[string]interface{}{
+			"type":        "string",
+			"description": "DNS label of lowercase letters, digits and hyphens, the instance is available at <subdomain>.storage.<system domain> instead of <instance id>.<system domain>",
+			"pattern":     dnsLabelPattern,
+			"maxLength":   63,
+		},
+	}
+	if instances == 1 {
+		if !update {
+			var gateways []interface{}
+			for _, name := range gatewayNames() {
+				gateways = append(gateways, name)
+			}
+			properties["gateway"] = map[string]interface{}{
+				"type":        "string",
+				"description": "Backend of the minio gateway, instance runs as a minio server when not specified",
+				"enum":        gateways,
+			}
+		}
+		properties["googlecredentials"] = map[string]interface{}{
+			"type":        []interface{}{"string", "object"},
+			"description": "Google Cloud service account key, as a JSON string or object, required for gcs gateway",
+		}
+		properties["project_id"] = map[string]interface{}{
+			"type":        "string",
+			"description": "Google Cloud project of gcs gateway, instead of the project of the service account key",
+		}
+		properties["azure_storage_account"] = map[string]interface{}{
+			"type":        "string",
+			"description": "Name of the Azure storage account, required for azure gateway",
+			"pattern":     azureStorageAccountPattern,
+		}
+		properties["azure_storage_key"] = map[string]interface{}{
+			"type":        "string",
+			"description": "Base64 encoded key of the Azure storage account, required for azure gateway",
+		}
+		properties["gateway_endpoint"] = map[string]interface{}{
+			"type":        "string",
+			"description": "Endpoint of the backend of azure, s3 and oss gateways, for Azure Stack, sovereign clouds or S3 compatible stores",
+			"pattern":     "^https?://",
+		}
+		properties["nas_path"] = map[string]interface{}{
+			"type":        "string",
+			"description": "Path where the NAS is mounted, required for nas gateway",
+			"pattern":     "^/",
+		}
+		properties["hdfs_namenode"] = map[string]interface{}{
+			"type":        "string",
+			"description": "Namenode of the HDFS cluster like hdfs://namenode:8200, required for hdfs gateway",
+			"pattern":     "^hdfs://",
+		}
+	}
+	if update {
+		// Parameters are merged over the previous ones, null removes a parameter.
+		for _, property := range properties {
+			p := property.(map[string]interface{})
+			if types, ok := p["type"].([]interface{}); ok {
+				p["type"] = append(types, "null")
+			} else {
+				p["type"] = []interface{}{p["type"], "null"}
+			}
+		}
+		properties["rotate_credentials"] = map[string]interface{}{
+			"type":        "boolean",
+			"description": "Replace the secret key with a generated one, the previous secret key stays valid for the grace period of the plan",
+		}
+		if instances != 1 {
+			properties["add_server_pool"] = map[string]interface{}{
+				"type":        "integer",
+				"description": "Number of instances of a server pool to be added to the instance, the existing instances keep their data",
+			}
+			properties["migrate_to_erasure"] = map[string]interface{}{
+				"type":        "boolean",
+				"description": "Migrate a single instance to the erasure plan: first mirrors its data to the new instances, then retires it when specified again",
+			}
+		}
+	}
+
+	schema := map[string]interface{}{
+		"$schema":              jsonSchemaDraft,
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	return schema
+}
+
+// bindingParameters - returns the JSON schema of the parameters of cf
+// bind-service and cf create-service-key. maxDuration is the maximum duration
+// of the temporary credentials of "sts" bindings.
+func bindingParameters(maxDuration time.Duration) map[string]interface{} {
+	stringEnum := func(description string, values ...string) map[string]interface{} {
+		enum := []interface{}{}
+		for _, v := range values {
+			enum = append(enum, v)
+		}
+		return map[string]interface{}{
+			"type":        "string",
+			"description": description,
+			"enum":        enum,
+		}
+	}
+	format := stringEnum("Format of the credentials", formatNames()...)
+
+	properties := map[string]interface{}{
+		"permission": stringEnum("Permission of the credentials",
+			permissionReadOnly, permissionWriteOnly, permissionReadWrite, permissionAdmin),
+		"buckets": map[string]interface{}{
+			"type":        "array",
+			"description": "Buckets the permission is narrowed down to",
+			"items":       map[string]interface{}{"type": "string"},
+		},
+		"prefix": map[string]interface{}{
+			"type":        "string",
+			"description": "Object prefix the permission is narrowed down to",
+		},
+		"policy": map[string]interface{}{
+			"type":        []interface{}{"string", "object"},
+			"description": "Name of a canned policy or a policy document, instead of permission",
+		},
+		"bucket": map[string]interface{}{
+			"type":        []interface{}{"string", "boolean"},
+			"description": "Bucket to be created for the binding, true names it after the binding ID",
+		},
+		"purge_bucket": map[string]interface{}{
+			"type":        "boolean",
+			"description": "Delete the bucket of the binding along with its objects on unbind",
+		},
+		"credential_type": stringEnum("Static keys or temporary STS credentials",
+			credentialTypeStatic, credentialTypeSTS),
+		"duration": map[string]interface{}{
+			"type": []interface{}{"integer", "string"},
+			"description": fmt.Sprintf("Duration of the sts credentials, in seconds or like \"1h\", between %s and %s",
+				minSTSDuration, maxDuration),
+		},
+		"format": map[string]interface{}{
+			"description": "Format or list of formats of the credentials",
+			"oneOf": []interface{}{
+				format,
+				map[string]interface{}{"type": "array", "items": format, "minItems": 1},
+			},
+		},
+		"endpoint": stringEnum("Primary endpoint of the credentials", endpointRouter, endpointInternal),
+		"credhub_ref": map[string]interface{}{
+			"type":        "boolean",
+			"description": "Store the credentials in CredHub and return a reference to them, for application bindings only",
+		},
+	}
+	return map[string]interface{}{
+		"$schema":              jsonSchemaDraft,
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+// planInstances - returns the number of instances of the plan, configured in the tile.
+func planInstances(plan serviceadapter.Plan) (int, error) {
+	instances, err := strconv.Atoi(fmt.Sprint(plan.Properties["instances"]))
+	if err != nil {
+		return 0, fmt.Errorf(`Unable to parse "instances": %s`, err.Error())
+	}
+	return instances, nil
+}
+
+// validateParameters - validates the parameters of cf create-service (update
+// is false) or cf update-service (update is true) against the schema published
+// by GeneratePlanSchema, every violation is reported in the error.
+func validateParameters(plan serviceadapter.Plan, params map[string]interface{}, update bool) error {
+	instances, err := planInstances(plan)
+	if err != nil {
+		return err
+	}
+	if errs := validateSchema(instanceParameters(instances, update), params); len(errs) > 0 {
+		return fmt.Errorf("invalid parameters: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// mergeParameters - returns the parameters of cf update-service merged over
+// the previous parameters of the instance, parameters set to null are removed.
+// Parameters in immutableParameters can not be changed.
+func mergeParameters(previous, update map[string]interface{}) (map[string]interface{}, error) {
+	for _, key := range immutableParameters {
+		if _, ok := update[key]; ok {
+			return nil, fmt.Errorf("%q can be specified only during instance creation", key)
+		}
+	}
+	params := make(map[string]interface{})
+	for key, value := range previous {
+		params[key] = value
+	}
+	for key, value := range update {
+		if value == nil {
+			delete(params, key)
+		} else {
+			params[key] = value
+		}
+	}
+	return params, nil
+}
+
+// GeneratePlanSchema - returns the JSON schemas of the parameters of the plan.
+func (a adapter) GeneratePlanSchema(plan serviceadapter.Plan) (schema serviceadapter.PlanSchema, err error) {
+	instances, err := planInstances(plan)
+	if err != nil {
+		return schema, err
+	}
+	schema.ServiceInstance.Create.Parameters = instanceParameters(instances, false)
+	schema.ServiceInstance.Update.Parameters = instanceParameters(instances, true)
+
+	config, _ := plan.Properties["bindings"].(map[string]interface{})
+	maxDuration, err := stsMaxDuration(config)
+	if err != nil {
+		return schema, err
+	}
+	schema.ServiceBinding.Create.Parameters = bindingParameters(maxDuration)
+	return schema, nil
+}
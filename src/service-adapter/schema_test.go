@@ -0,0 +1,172 @@
+/*
+ * Minio Cloud Storage, (C) 2019 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPlanSchemaSTSMaxDuration(t *testing.T) {
+	testCases := []struct {
+		maxDuration interface{}
+		valid       bool
+	}{
+		{nil, true},
+		{"15m", true},
+		{"2h", true},
+		{float64(43200), true},
+		{"5m", false},
+		{"24h", false},
+		{float64(60), false},
+		{"forever", false},
+	}
+	for _, tc := range testCases {
+		plan := testServicePlan("1")
+		if tc.maxDuration != nil {
+			plan.Properties["bindings"] = map[string]interface{}{"sts_max_duration": tc.maxDuration}
+		}
+		_, err := adapter{}.GeneratePlanSchema(plan)
+		if (err == nil) != tc.valid {
+			t.Errorf("%v: expected valid %v, got %v", tc.maxDuration, tc.valid, err)
+		}
+		if _, derr := stsDuration(map[string]interface{}{"sts_max_duration": tc.maxDuration}, map[string]interface{}{}); tc.maxDuration != nil && (derr == nil) != tc.valid {
+			t.Errorf("%v: expected the bind to agree with the plan schema, got %v", tc.maxDuration, derr)
+		}
+	}
+}
+
+func TestValidateParameters(t *testing.T) {
+	subdomain := `"subdomain" is not valid: `
+	testCases := []struct {
+		instances string
+		update    bool
+		params    map[string]interface{}
+		errs      []string
+	}{
+		{"4", false, map[string]interface{}{}, nil},
+		// The secret key of an instance is generated when missing.
+		{"4", false, map[string]interface{}{"accesskey": "admin"}, nil},
+		{"4", false, map[string]interface{}{"accesskey": "admin", "secretkey": "12345678"}, nil},
+		{"4", false, map[string]interface{}{"secretkey": "1234567"}, []string{`"secretkey" should be at least 8 characters long`}},
+		{"4", false, map[string]interface{}{"accesskey": "ab"}, []string{`"accesskey" should be at least 3 characters long`}},
+		{"4", false, map[string]interface{}{"accesskey": 5}, []string{`"accesskey" should be of type string`}},
+		{"4", false, map[string]interface{}{"subdomain": "my-bucket-store"}, nil},
+		{"4", false, map[string]interface{}{"subdomain": "Not_A_Label"}, []string{subdomain}},
+		{"4", false, map[string]interface{}{"subdomain": "-store"}, []string{subdomain}},
+		{"4", false, map[string]interface{}{"subdomain": "store.example"}, []string{subdomain}},
+		{"4", false, map[string]interface{}{"subdomain": strings.Repeat("a", 64)}, []string{`"subdomain"`}},
+		{"4", false, map[string]interface{}{"bogus": true}, []string{`"bogus" is not a supported parameter`}},
+		{"1", false, map[string]interface{}{"gateway": "azure"}, nil},
+		{"1", false, map[string]interface{}{"gateway": "ftp"}, []string{`"gateway" should be one of "azure", "b2", "gcs", "hdfs", "nas", "oss", "s3"`}},
+		{"1", false, map[string]interface{}{"nas_path": "mnt"}, []string{`"nas_path" is not valid`}},
+		// Gateways and their parameters are offered only for a single instance.
+		{"4", false, map[string]interface{}{"gateway": "nas"}, []string{`"gateway" is not a supported parameter`}},
+		{"4", false, map[string]interface{}{"nas_path": "/mnt"}, []string{`"nas_path" is not a supported parameter`}},
+		// The gateway can not be changed by cf update-service.
+		{"1", true, map[string]interface{}{"gateway": "nas"}, []string{`"gateway" is not a supported parameter`}},
+		{"1", true, map[string]interface{}{"nas_path": nil, "rotate_credentials": true}, nil},
+		{"1", true, map[string]interface{}{"add_server_pool": 4}, []string{`"add_server_pool" is not a supported parameter`}},
+		{"1", false, map[string]interface{}{"rotate_credentials": true}, []string{`"rotate_credentials" is not a supported parameter`}},
+		{"4", true, map[string]interface{}{"add_server_pool": 4, "migrate_to_erasure": true, "secretkey": nil}, nil},
+		{"4", true, map[string]interface{}{"accesskey": 5}, []string{`"accesskey" should be of type string or null`}},
+		// Every violation is reported at once.
+		{"4", false, map[string]interface{}{"accesskey": 5, "bogus": true, "secretkey": "short", "subdomain": "-x"}, []string{
+			`"accesskey" should be of type string`,
+			`"bogus" is not a supported parameter`,
+			`"secretkey" should be at least 8 characters long`,
+			subdomain,
+		}},
+	}
+	for i, tc := range testCases {
+		err := validateParameters(testServicePlan(tc.instances), tc.params, tc.update)
+		if len(tc.errs) == 0 {
+			if err != nil {
+				t.Errorf("Test %d: %v: expected valid, got %v", i+1, tc.params, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("Test %d: %v: expected %q, got no error", i+1, tc.params, tc.errs)
+			continue
+		}
+		if !strings.HasPrefix(err.Error(), "invalid parameters: ") {
+			t.Errorf("Test %d: unexpected error %v", i+1, err)
+			continue
+		}
+		violations := strings.Split(strings.TrimPrefix(err.Error(), "invalid parameters: "), "; ")
+		if len(violations) != len(tc.errs) {
+			t.Errorf("Test %d: expected %d violations, got %q", i+1, len(tc.errs), violations)
+			continue
+		}
+		for j, expected := range tc.errs {
+			if !strings.HasPrefix(violations[j], expected) {
+				t.Errorf("Test %d: expected %q, got %q", i+1, expected, violations[j])
+			}
+		}
+	}
+}
+
+func TestPlanSchemaParameters(t *testing.T) {
+	gatewayParameters := []string{"googlecredentials", "project_id", "azure_storage_account",
+		"azure_storage_key", "gateway_endpoint", "nas_path", "hdfs_namenode"}
+	testCases := []struct {
+		instances string
+		update    bool
+		present   []string
+		absent    []string
+	}{
+		{"1", false, append([]string{"accesskey", "secretkey", "subdomain", "gateway"}, gatewayParameters...),
+			[]string{"rotate_credentials", "add_server_pool", "migrate_to_erasure"}},
+		{"1", true, append([]string{"accesskey", "secretkey", "subdomain", "rotate_credentials"}, gatewayParameters...),
+			[]string{"gateway", "add_server_pool", "migrate_to_erasure"}},
+		{"4", false, []string{"accesskey", "secretkey", "subdomain"},
+			append([]string{"gateway", "rotate_credentials", "add_server_pool", "migrate_to_erasure"}, gatewayParameters...)},
+		{"4", true, []string{"accesskey", "secretkey", "subdomain", "rotate_credentials", "add_server_pool", "migrate_to_erasure"},
+			append([]string{"gateway"}, gatewayParameters...)},
+	}
+	for _, tc := range testCases {
+		schema, err := adapter{}.GeneratePlanSchema(testServicePlan(tc.instances))
+		if err != nil {
+			t.Fatal(err)
+		}
+		published := schema.ServiceInstance.Create.Parameters
+		if tc.update {
+			published = schema.ServiceInstance.Update.Parameters
+		}
+		// The published schema is the one parameters are validated against.
+		instances, _ := planInstances(testServicePlan(tc.instances))
+		if !reflect.DeepEqual(published, instanceParameters(instances, tc.update)) {
+			t.Errorf("%s instances, update %v: published schema differs from the validated one", tc.instances, tc.update)
+		}
+		if published["additionalProperties"] != false {
+			t.Errorf("%s instances, update %v: expected unknown parameters to be refused", tc.instances, tc.update)
+		}
+		properties := published["properties"].(map[string]interface{})
+		for _, name := range tc.present {
+			if _, ok := properties[name]; !ok {
+				t.Errorf("%s instances, update %v: expected %q in the schema", tc.instances, tc.update, name)
+			}
+		}
+		for _, name := range tc.absent {
+			if _, ok := properties[name]; ok {
+				t.Errorf("%s instances, update %v: unexpected %q in the schema", tc.instances, tc.update, name)
+			}
+		}
+	}
+}
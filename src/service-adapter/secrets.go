@@ -0,0 +1,72 @@
+/*
+ * Minio Cloud Storage, (C) 2019 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+// Parameters which are handed over to ODB as ODB-managed secrets, the manifest
+// only references them with ((odb_secret:<parameter>)).
+var secretParameters = []string{"accesskey", "secretkey", "googlecredentials", "azure_storage_account", "azure_storage_key", "tls_certificate", "tls_private_key"}
+
+func isSecretParameter(name string) bool {
+	for _, p := range secretParameters {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+func isReference(value string) bool {
+	return strings.HasPrefix(value, "((") && strings.HasSuffix(value, "))")
+}
+
+func odbSecretReference(name string) string {
+	return fmt.Sprintf("((%s:%s))", serviceadapter.ODBSecretPrefix, name)
+}
+
+// manageSecrets - returns a copy of the parameters where the secret parameters
+// are replaced by references to ODB-managed secrets, which are added to
+// odbSecrets. Secret parameters of the previous manifest are references,
+// their values are looked up in previousSecrets.
+func manageSecrets(params map[string]interface{}, previousSecrets serviceadapter.ManifestSecrets, odbSecrets serviceadapter.ODBManagedSecrets) (map[string]interface{}, error) {
+	managed := make(map[string]interface{})
+	for k, v := range params {
+		managed[k] = v
+	}
+	for _, name := range secretParameters {
+		value, ok := params[name].(string)
+		if !ok {
+			continue
+		}
+		if isReference(value) {
+			secret := resolveSecret(value, previousSecrets)
+			if secret == "" {
+				return nil, fmt.Errorf("unable to find the value of %s in the previous secrets", value)
+			}
+			value = secret
+		}
+		odbSecrets[name] = value
+		managed[name] = odbSecretReference(name)
+	}
+	return managed, nil
+}
@@ -0,0 +1,93 @@
+/*
+ * Minio Cloud Storage, (C) 2019 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AWS Signature Version 4, used to authenticate the requests made to the
+// Minio admin, S3 and STS APIs of an instance.
+const (
+	signV4Algorithm = "AWS4-HMAC-SHA256"
+	iso8601Format   = "20060102T150405Z"
+	yyyymmdd        = "20060102"
+)
+
+func sum256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func sumHMAC(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// Query values are encoded as per the AWS canonical query string rules.
+func canonicalQuery(req *http.Request) string {
+	return strings.Replace(req.URL.Query().Encode(), "+", "%20", -1)
+}
+
+// signV4 - signs the request with the given credentials, payload is the
+// request body which is hashed into X-Amz-Content-Sha256.
+func signV4(req *http.Request, payload []byte, accessKey, secretKey, region, service string) {
+	t := time.Now().UTC()
+	payloadHash := sum256Hex(payload)
+	req.Header.Set("X-Amz-Date", t.Format(iso8601Format))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := fmt.Sprintf("host:%s\nx-amz-content-sha256:%s\nx-amz-date:%s\n",
+		req.URL.Host, payloadHash, t.Format(iso8601Format))
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{t.Format(yyyymmdd), region, service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		signV4Algorithm,
+		t.Format(iso8601Format),
+		scope,
+		sum256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signingKey := sumHMAC([]byte("AWS4"+secretKey), t.Format(yyyymmdd))
+	signingKey = sumHMAC(signingKey, region)
+	signingKey = sumHMAC(signingKey, service)
+	signingKey = sumHMAC(signingKey, "aws4_request")
+	signature := hex.EncodeToString(sumHMAC(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		signV4Algorithm, accessKey, scope, signedHeaders, signature))
+}
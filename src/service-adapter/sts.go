@@ -0,0 +1,164 @@
+/*
+ * Minio Cloud Storage, (C) 2019 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Limits of the duration of the temporary credentials returned by AssumeRole.
+const (
+	minSTSDuration     = 15 * time.Minute
+	maxSTSDuration     = 12 * time.Hour
+	defaultSTSDuration = time.Hour
+)
+
+// Credential types which can be requested for a binding with the
+// "credential_type" bind parameter.
+const (
+	credentialTypeStatic = "static"
+	credentialTypeSTS    = "sts"
+)
+
+// temporaryCredentials - credentials returned by the Minio STS API.
+type temporaryCredentials struct {
+	AccessKey    string    `xml:"AccessKeyId"`
+	SecretKey    string    `xml:"SecretAccessKey"`
+	SessionToken string    `xml:"SessionToken"`
+	Expiration   time.Time `xml:"Expiration"`
+}
+
+// stsClient - minimal client for the Minio STS API of an instance. Temporary
+// credentials are issued for the user whose credentials sign the request and
+// carry the policy of that user.
+type stsClient struct {
+	minioClient
+}
+
+func newSTSClient(endpoint, accessKey, secretKey string) *stsClient {
+	c := &stsClient{newMinioClient(endpoint, accessKey, secretKey)}
+	c.service = "sts"
+	return c
+}
+
+// AssumeRole - returns temporary credentials valid for the given duration.
+func (c *stsClient) AssumeRole(duration time.Duration) (creds temporaryCredentials, err error) {
+	values := url.Values{
+		"Action":          {"AssumeRole"},
+		"Version":         {"2011-06-15"},
+		"DurationSeconds": {fmt.Sprint(int(duration.Seconds()))},
+	}
+	header := http.Header{}
+	header.Set("Content-Type", "application/x-www-form-urlencoded")
+	b, status, err := c.execute(http.MethodPost, "/", nil, header, []byte(values.Encode()))
+	if err != nil {
+		return creds, err
+	}
+	if status != http.StatusOK {
+		var resp struct {
+			Error apiError `xml:"Error"`
+		}
+		xml.Unmarshal(b, &resp)
+		resp.Error.StatusCode = status
+		return creds, resp.Error
+	}
+	var resp struct {
+		Credentials temporaryCredentials `xml:"AssumeRoleResult>Credentials"`
+	}
+	if err = xml.Unmarshal(b, &resp); err != nil {
+		return creds, err
+	}
+	return resp.Credentials, nil
+}
+
+// parseDuration - parses a duration given either as a number of seconds or as
+// a string like "1h30m".
+func parseDuration(key string, value interface{}) (time.Duration, error) {
+	switch v := value.(type) {
+	case float64:
+		return time.Duration(v) * time.Second, nil
+	case int:
+		return time.Duration(v) * time.Second, nil
+	case string:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return 0, fmt.Errorf(`"%s" is not a valid duration: %s`, key, err)
+		}
+		return d, nil
+	default:
+		return 0, fmt.Errorf(`"%s" should be a number of seconds or a duration like "1h"`, key)
+	}
+}
+
+// stsMaxDuration - returns "sts_max_duration" of the plan bindings
+// configuration, the longest duration of the temporary credentials.
+func stsMaxDuration(config map[string]interface{}) (time.Duration, error) {
+	if config["sts_max_duration"] == nil {
+		return maxSTSDuration, nil
+	}
+	d, err := parseDuration("sts_max_duration", config["sts_max_duration"])
+	if err != nil {
+		return 0, err
+	}
+	if d < minSTSDuration || d > maxSTSDuration {
+		return 0, fmt.Errorf(`"sts_max_duration" should be between %s and %s`, minSTSDuration, maxSTSDuration)
+	}
+	return d, nil
+}
+
+// stsDuration - returns the duration of the temporary credentials requested
+// with the "duration" bind parameter. It can not exceed "sts_max_duration" of
+// the plan bindings configuration.
+func stsDuration(config, params map[string]interface{}) (time.Duration, error) {
+	maxDuration, err := stsMaxDuration(config)
+	if err != nil {
+		return 0, err
+	}
+	if params["duration"] == nil {
+		if defaultSTSDuration > maxDuration {
+			return maxDuration, nil
+		}
+		return defaultSTSDuration, nil
+	}
+	d, err := parseDuration("duration", params["duration"])
+	if err != nil {
+		return 0, err
+	}
+	if d < minSTSDuration || d > maxDuration {
+		return 0, fmt.Errorf(`"duration" should be between %s and %s`, minSTSDuration, maxDuration)
+	}
+	return d, nil
+}
+
+func bindingCredentialType(params map[string]interface{}) (string, error) {
+	switch t := params["credential_type"].(type) {
+	case nil:
+		return credentialTypeStatic, nil
+	case string:
+		if t != credentialTypeStatic && t != credentialTypeSTS {
+			return "", fmt.Errorf(`"%s" credential type is not supported, valid credential types are: %s, %s`, t, credentialTypeStatic, credentialTypeSTS)
+		}
+		return t, nil
+	default:
+		return "", errors.New(`"credential_type" should be a string`)
+	}
+}
@@ -0,0 +1,97 @@
+/*
+ * Minio Cloud Storage, (C) 2019 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
+)
+
+// Plan property naming the operator CA in CredHub, like /services/minio_ca,
+// which signs the certificates of the instances. Instances of the plans
+// without a CA are served over plain HTTP.
+const tlsCAProperty = "tls_ca"
+
+// Certificate variable of the instance and the link through which it gets the
+// BOSH DNS addresses of the minio job.
+const (
+	tlsCertificateVariable = "minio_tls"
+	tlsAddressLink         = "minio-tls-address"
+)
+
+// generatedCertificate - returns the certificate variable of the instance
+// signed by ca, for domain and the BOSH DNS addresses of the minio job.
+func generatedCertificate(ca, domain string) bosh.Variable {
+	return bosh.Variable{
+		Name: tlsCertificateVariable,
+		Type: "certificate",
+		Options: map[string]interface{}{
+			"ca":                 ca,
+			"common_name":        domain,
+			"alternative_names":  []string{domain},
+			"extended_key_usage": []string{"server_auth"},
+		},
+		Consumes: &bosh.VariableConsumes{
+			AlternativeName: bosh.VariableConsumesLink{
+				From:       tlsAddressLink,
+				Properties: map[string]interface{}{"wildcard": true},
+			},
+		},
+	}
+}
+
+// certificateProperties - returns the "tls" property of the minio job
+// referencing the certificate variable.
+func certificateProperties(variable string) map[string]string {
+	return map[string]string{
+		"certificate": "((" + variable + ".certificate))",
+		"private_key": "((" + variable + ".private_key))",
+		"ca":          "((" + variable + ".ca))",
+	}
+}
+
+// validateCertificate - validates the certificate and the private key provided
+// with the "tls_certificate" and "tls_private_key" parameters: both are PEM
+// encoded, the key matches the certificate, which is valid at now for domain.
+func validateCertificate(certificate, key, domain string, now time.Time) error {
+	if certificate == "" || key == "" {
+		return errors.New(`"tls_certificate" and "tls_private_key" should be provided together`)
+	}
+	pair, err := tls.X509KeyPair([]byte(certificate), []byte(key))
+	if err != nil {
+		return fmt.Errorf(`"tls_certificate" and "tls_private_key" are not valid: %s`, err)
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return fmt.Errorf(`"tls_certificate" is not valid: %s`, err)
+	}
+	if now.After(cert.NotAfter) {
+		return fmt.Errorf(`"tls_certificate" expired on %s`, cert.NotAfter.UTC().Format(time.RFC3339))
+	}
+	if now.Before(cert.NotBefore) {
+		return fmt.Errorf(`"tls_certificate" is not valid before %s`, cert.NotBefore.UTC().Format(time.RFC3339))
+	}
+	if err = cert.VerifyHostname(domain); err != nil {
+		return fmt.Errorf(`"tls_certificate" is not valid for %s: %s`, domain, err)
+	}
+	return nil
+}
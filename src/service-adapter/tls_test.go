@@ -0,0 +1,154 @@
+/*
+ * Minio Cloud Storage, (C) 2019 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCertificate - returns a self-signed certificate for the domains, valid
+// from notBefore to notAfter, and its private key, PEM encoded.
+func testCertificate(t *testing.T, domains []string, notBefore, notAfter time.Time) (certificate, key string) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: domains[0]},
+		DNSNames:     domains,
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: b}))
+}
+
+func TestValidateCertificate(t *testing.T) {
+	now := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	domain := "3a8c5ba6.sys.example.com"
+	certificate, key := testCertificate(t, []string{domain}, now.Add(-time.Hour), now.Add(24*time.Hour))
+	wildcard, wildcardKey := testCertificate(t, []string{"*.sys.example.com"}, now.Add(-time.Hour), now.Add(24*time.Hour))
+	_, otherKey := testCertificate(t, []string{domain}, now.Add(-time.Hour), now.Add(24*time.Hour))
+	expired, expiredKey := testCertificate(t, []string{domain}, now.Add(-48*time.Hour), now.Add(-24*time.Hour))
+	future, futureKey := testCertificate(t, []string{domain}, now.Add(24*time.Hour), now.Add(48*time.Hour))
+	other, otherDomainKey := testCertificate(t, []string{"other.sys.example.com"}, now.Add(-time.Hour), now.Add(24*time.Hour))
+
+	testCases := []struct {
+		name        string
+		certificate string
+		key         string
+		refused     string
+	}{
+		{"valid", certificate, key, ""},
+		{"wildcard", wildcard, wildcardKey, ""},
+		{"no key", certificate, "", "should be provided together"},
+		{"no certificate", "", key, "should be provided together"},
+		{"not PEM", "not a certificate", key, "are not valid"},
+		{"mismatched key", certificate, otherKey, "are not valid"},
+		{"expired", expired, expiredKey, "expired on " + now.Add(-24*time.Hour).Format(time.RFC3339)},
+		{"not yet valid", future, futureKey, "is not valid before " + now.Add(24*time.Hour).Format(time.RFC3339)},
+		{"other domain", other, otherDomainKey, "is not valid for " + domain},
+	}
+	for _, tc := range testCases {
+		err := validateCertificate(tc.certificate, tc.key, domain, now)
+		if tc.refused == "" && err != nil {
+			t.Errorf("%s: unexpected error %s", tc.name, err)
+		}
+		if tc.refused != "" && (err == nil || !strings.Contains(err.Error(), tc.refused)) {
+			t.Errorf("%s: expected %q, got %v", tc.name, tc.refused, err)
+		}
+	}
+}
+
+func TestProvidedCertificate(t *testing.T) {
+	now := time.Now()
+	certificate, key := testCertificate(t, []string{"3a8c5ba6.sys.example.com"}, now.Add(-time.Hour), now.Add(24*time.Hour))
+	output, err := generateTestManifest(t, testServicePlan("1"), map[string]interface{}{
+		"tls_certificate": certificate, "tls_private_key": key,
+	}, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output.ODBManagedSecrets["tls_certificate"] != certificate || output.ODBManagedSecrets["tls_private_key"] != key {
+		t.Fatalf("unexpected secrets %v", output.ODBManagedSecrets)
+	}
+	expected := map[string]string{
+		"certificate": "((odb_secret:tls_certificate))",
+		"private_key": "((odb_secret:tls_private_key))",
+	}
+	if tls := output.Manifest.Properties["tls"]; !reflect.DeepEqual(tls, expected) {
+		t.Fatalf("unexpected tls %v", tls)
+	}
+	if params := output.Manifest.Properties["parameters"].(map[string]interface{}); params["tls_certificate"] != expected["certificate"] {
+		t.Fatalf("unexpected parameters %v", params)
+	}
+
+	// Certificate of another domain is refused.
+	certificate, key = testCertificate(t, []string{"other.sys.example.com"}, now.Add(-time.Hour), now.Add(24*time.Hour))
+	_, err = generateTestManifest(t, testServicePlan("1"), map[string]interface{}{
+		"tls_certificate": certificate, "tls_private_key": key,
+	}, nil, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "is not valid for 3a8c5ba6.sys.example.com") {
+		t.Fatalf("expected the certificate to be refused, got %v", err)
+	}
+}
+
+func TestGeneratedCertificate(t *testing.T) {
+	plan := testServicePlan("1")
+	plan.Properties[tlsCAProperty] = "/services/minio_ca"
+	output, err := generateTestManifest(t, plan, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGoldenManifest(t, "tls-generated-certificate", output.Manifest)
+
+	// Certificate provided with -c takes precedence over the CA of the plan.
+	now := time.Now()
+	certificate, key := testCertificate(t, []string{"3a8c5ba6.sys.example.com"}, now.Add(-time.Hour), now.Add(24*time.Hour))
+	output, err = generateTestManifest(t, plan, map[string]interface{}{
+		"tls_certificate": certificate, "tls_private_key": key,
+	}, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, variable := range output.Manifest.Variables {
+		if variable.Name == tlsCertificateVariable {
+			t.Fatalf("unexpected variable %s", variable.Name)
+		}
+	}
+}